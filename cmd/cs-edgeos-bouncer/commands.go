@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jacobalberty/cs-edgeos-bouncer/internal/bouncer"
+	"github.com/jacobalberty/cs-edgeos-bouncer/internal/config"
+	"github.com/jacobalberty/cs-edgeos-bouncer/internal/decisions"
+)
+
+type command struct {
+	args, help string
+	run        func(ctx context.Context, cfg *config.Config, args []string) error
+}
+
+var commands = map[string]command{
+	"run":       {"", "run the bouncer (the default)", serve},
+	"status":    {"", "show whether each router is reachable and its group sizes", status},
+	"list":      {"", "list the managed entries in the state file", list},
+	"diff":      {"", "show what a reconcile would change on each router", diff},
+	"add":       {"VALUE...", "add addresses or prefixes to every router's groups", add},
+	"remove":    {"VALUE...", "remove addresses or prefixes from every router's groups", remove},
+	"flush":     {"", "remove every managed entry from the routers and the state file", flush},
+	"export":    {"[-format F] [-router NAME]", "write a router's groups as text, csv or json", export},
+	"import":    {"[-format F] [FILE]", "add a text, csv or json ban list to every router's groups", importList},
+	"reconcile": {"", "push the state file to every router now", reconcile},
+}
+
+func usage() string {
+	var b strings.Builder
+	b.WriteString("usage: cs-edgeos-bouncer [command]\n\ncommands:\n")
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	for _, name := range slices.Sorted(maps.Keys(commands)) {
+		c := commands[name]
+		fmt.Fprintf(w, "  %s %s\t%s\n", name, c.args, c.help)
+	}
+	w.Flush()
+
+	return b.String()
+}
+
+// routersFor loads the state and builds the routers for a one-off command.
+func routersFor(cfg *config.Config) (*decisions.Set, []*bouncer.Router, error) {
+	desired, owned, err := loadDesired(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	routers, err := newRouters(cfg, owned)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return desired, routers, nil
+}
+
+// eachRouter calls fn for every router, carrying on past failures so one
+// unreachable router doesn't hide the others.
+func eachRouter(routers []*bouncer.Router, fn func(r *bouncer.Router) error) error {
+	var errs []error
+	for _, r := range routers {
+		if err := fn(r); err != nil {
+			errs = append(errs, fmt.Errorf("router %s: %w", r.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func status(_ context.Context, cfg *config.Config, _ []string) error {
+	_, routers, err := routersFor(cfg)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintln(w, "ROUTER\tMODEL\tVERSION\tGROUP\tENTRIES")
+
+	return eachRouter(routers, func(r *bouncer.Router) error {
+		st, err := r.Status()
+		if err != nil {
+			fmt.Fprintf(w, "%s\tunreachable\t\t\t\n", r.Name)
+			return err
+		}
+		for _, g := range st.Groups {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\n", r.Name, st.Model, st.Version, g.Name, g.Entries)
+		}
+		return nil
+	})
+}
+
+func list(_ context.Context, cfg *config.Config, _ []string) error {
+	if cfg.State.File == "" {
+		return errors.New("STATE_FILE is not set")
+	}
+	desired, _, err := loadDesired(cfg)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintln(w, "VALUE\tORIGIN\tSCENARIO\tUNTIL")
+	for _, e := range desired.Entries() {
+		until := ""
+		if !e.Until.IsZero() {
+			until = e.Until.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.Value, e.Origin, e.Scenario, until)
+	}
+
+	return nil
+}
+
+func diff(_ context.Context, cfg *config.Config, _ []string) error {
+	desired, routers, err := routersFor(cfg)
+	if err != nil {
+		return err
+	}
+	want := desired.Entries()
+
+	return eachRouter(routers, func(r *bouncer.Router) error {
+		diffs, err := r.Diff(want)
+		if err != nil {
+			return err
+		}
+		for _, d := range diffs {
+			fmt.Printf("%s %s\n", r.Name, d.Name)
+			for _, v := range d.Remove {
+				fmt.Printf("- %s\n", v)
+			}
+			for _, v := range d.Add {
+				fmt.Printf("+ %s\n", v)
+			}
+		}
+		return nil
+	})
+}
+
+// add and remove change the routers directly. The entries aren't recorded
+// as managed, so a running bouncer reverts them on its next push unless
+// ER_KEEP_UNMANAGED is set.
+func add(_ context.Context, cfg *config.Config, args []string) error {
+	return apply(cfg, args, nil)
+}
+
+func remove(_ context.Context, cfg *config.Config, args []string) error {
+	return apply(cfg, nil, args)
+}
+
+func apply(cfg *config.Config, add, remove []string) error {
+	if len(add)+len(remove) == 0 {
+		return errors.New("no values given")
+	}
+	for _, v := range slices.Concat(add, remove) {
+		if bouncer.KindOf(v) == bouncer.KindUnsupported {
+			return fmt.Errorf("unsupported value %q", v)
+		}
+	}
+	_, routers, err := routersFor(cfg)
+	if err != nil {
+		return err
+	}
+
+	return eachRouter(routers, func(r *bouncer.Router) error {
+		return r.Apply(add, remove)
+	})
+}
+
+func flush(_ context.Context, cfg *config.Config, _ []string) error {
+	_, routers, err := routersFor(cfg)
+	if err != nil {
+		return err
+	}
+	if err := eachRouter(routers, func(r *bouncer.Router) error {
+		return r.Sync(nil)
+	}); err != nil {
+		// Keep the state so the entries are still known to be ours.
+		return err
+	}
+	if cfg.State.File == "" {
+		return nil
+	}
+
+	return decisions.NewSet().Save(cfg.State.File)
+}
+
+func reconcile(_ context.Context, cfg *config.Config, _ []string) error {
+	// Without a state file the desired set is empty and reconciling would
+	// flush the routers.
+	if cfg.State.File == "" {
+		return errors.New("STATE_FILE is not set")
+	}
+	desired, routers, err := routersFor(cfg)
+	if err != nil {
+		return err
+	}
+	want := desired.Entries()
+
+	return eachRouter(routers, func(r *bouncer.Router) error {
+		return r.Sync(want)
+	})
+}
+
+// formatFor returns the named format, or one guessed from the file extension
+// when no name is given.
+func formatFor(name, file string) (decisions.Format, error) {
+	if name == "" {
+		switch filepath.Ext(file) {
+		case ".csv":
+			return decisions.FormatCSV, nil
+		case ".json":
+			return decisions.FormatJSON, nil
+		default:
+			return decisions.FormatText, nil
+		}
+	}
+
+	return decisions.ParseFormat(name)
+}
+
+// export writes the values in a router's groups, along with the decision
+// metadata from the state file for those the bouncer manages.
+func export(_ context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "text", "output format: text, csv or json")
+	router := fs.String("router", "", "router to export, defaults to the first")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	f, err := decisions.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+
+	desired, routers, err := routersFor(cfg)
+	if err != nil {
+		return err
+	}
+	r := routers[0]
+	if *router != "" {
+		i := slices.IndexFunc(routers, func(r *bouncer.Router) bool { return r.Name == *router })
+		if i < 0 {
+			return fmt.Errorf("unknown router %q", *router)
+		}
+		r = routers[i]
+	}
+
+	values, err := r.Values()
+	if err != nil {
+		return err
+	}
+	entries := make([]decisions.Entry, len(values))
+	for i, v := range values {
+		if e, ok := desired.Get(v); ok {
+			entries[i] = e
+		} else {
+			entries[i] = decisions.Entry{Value: v}
+		}
+	}
+
+	return decisions.Export(os.Stdout, f, entries)
+}
+
+// importList adds a ban list read from a file, or stdin, to the routers. Like
+// add, the entries aren't recorded as managed.
+func importList(_ context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", "", "input format: text, csv or json, guessed from the file name if unset")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	in, name := io.Reader(os.Stdin), ""
+	if fs.NArg() > 0 {
+		name = fs.Arg(0)
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+	f, err := formatFor(*format, name)
+	if err != nil {
+		return err
+	}
+	entries, err := decisions.Import(in, f)
+	if err != nil {
+		return err
+	}
+
+	values := make([]string, len(entries))
+	for i, e := range entries {
+		values[i] = e.Value
+	}
+	slog.Info("importing entries", "entries", len(values))
+
+	return apply(cfg, values, nil)
+}
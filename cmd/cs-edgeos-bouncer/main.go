@@ -2,146 +2,231 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
-	"net"
+	"io"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"time"
 
 	csbouncer "github.com/crowdsecurity/go-cs-bouncer"
+	"github.com/jacobalberty/cs-edgeos-bouncer/internal/audit"
+	"github.com/jacobalberty/cs-edgeos-bouncer/internal/bouncer"
 	"github.com/jacobalberty/cs-edgeos-bouncer/internal/config"
-	"github.com/jacobalberty/cs-edgeos-bouncer/pkg/xedgeos"
+	"github.com/jacobalberty/cs-edgeos-bouncer/internal/decisions"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"golang.org/x/sync/errgroup"
 )
 
 func main() {
 	ctx := context.Background()
-	if err := run(ctx); err != nil {
+	if err := run(ctx, os.Args[1:]); err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err)
 		os.Exit(1)
 	}
 }
 
-func run(ctx context.Context) error {
+func run(ctx context.Context, args []string) error {
 	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
 	defer cancel()
 
+	name := "run"
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("unknown command %q\n%s", name, usage())
+	}
+
 	cfg, err := config.GetConfig()
 	if err != nil {
 		return err
 	}
-
-	bouncer := &csbouncer.StreamBouncer{
-		APIKey:         cfg.CSApi.Key,
-		APIUrl:         cfg.CSApi.Url,
-		TickerInterval: "20s",
+	logger, err := newLogger(cfg.Log, os.Stderr)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	if cfg.Audit.File != "" {
+		auditLog = audit.Open(cfg.Audit)
+		defer auditLog.Close()
 	}
 
-	if err := bouncer.Init(); err != nil {
-		return err
+	return cmd.run(ctx, cfg, args)
+}
+
+// auditLog is shared by every router, nil when auditing is off.
+var auditLog *audit.Log
+
+// newLogger returns a logger writing to w in the configured format and
+// level.
+func newLogger(cfg config.LogConfig, w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, fmt.Errorf("log level: %w", err)
+	}
+	opts := &slog.HandlerOptions{Level: level}
+
+	switch strings.ToLower(cfg.Format) {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", cfg.Format)
 	}
+}
 
-	eg, gctx := errgroup.WithContext(ctx)
+// loadDesired returns the desired set, resumed from the state file if one
+// is configured. owned is every entry in the state file, including those
+// that expired while the bouncer was down, as they may still be on the
+// routers.
+func loadDesired(cfg *config.Config) (desired *decisions.Set, owned []decisions.Entry, err error) {
+	desired = decisions.NewSet()
+	if cfg.State.File != "" {
+		if err := desired.Load(cfg.State.File); err != nil {
+			return nil, nil, err
+		}
+		owned = desired.Entries()
+		desired.Expire(time.Now())
+	}
 
-	eg.Go(func() error {
-		bouncer.Run(gctx)
-		cancel()
+	return desired, owned, nil
+}
 
-		return nil
-	})
+// newRouters builds the configured routers. owned lists the entries the
+// bouncer previously added, anything else on a router was added by someone
+// else and is left alone when KeepUnmanaged is set.
+func newRouters(cfg *config.Config, owned []decisions.Entry) ([]*bouncer.Router, error) {
+	if cfg.ERApi.AllowlistLAN && len(cfg.ERApi.AllowlistLANInterfaces) == 0 {
+		return nil, errors.New("ER_ALLOWLIST_LAN needs ER_ALLOWLIST_LAN_INTERFACES")
+	}
+	policy, err := decisions.ParsePolicy(cfg.ERApi.EvictionPolicy)
+	if err != nil {
+		return nil, err
+	}
 
-	eg.Go(func() error {
-		erClient, err := xedgeos.NewClient(cfg.ERApi.Url, cfg.ERApi.User, cfg.ERApi.Pass)
+	var routers []*bouncer.Router
+	for _, rc := range cfg.ERApi.AllRouters() {
+		r, err := bouncer.NewRouter(rc, cfg.ERApi.KeepUnmanaged, owned)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		if err = erClient.Login(); err != nil {
-			return err
-		}
-		r, err := erClient.Get()
-		if err != nil {
-			return err
+		r.AllowDHCP = cfg.ERApi.AllowlistDHCP
+		r.AllowLAN = cfg.ERApi.AllowlistLAN
+		r.LANInterfaces = cfg.ERApi.AllowlistLANInterfaces
+		r.MaxEntries = cfg.ERApi.MaxEntries
+		r.Policy = policy
+		r.BatchSize = cfg.ERApi.BatchSize
+		r.BatchMax = cfg.ERApi.BatchMax
+		r.BatchTarget = cfg.ERApi.BatchTarget
+		r.ReplaceThreshold = cfg.ERApi.ReplaceThreshold
+		r.SwapGroup = cfg.ERApi.SwapGroup
+		r.Audit = auditLog
+		if cfg.ERApi.Bootstrap {
+			r.Bootstrap = &bouncer.Bootstrap{
+				Description: cfg.ERApi.GroupDescription,
+				Rulesets:    cfg.ERApi.BootstrapRulesets,
+				Rule:        cfg.ERApi.BootstrapRule,
+			}
 		}
-		ag, err := xedgeos.NewAddressGroups(r)
-		if err != nil {
-			return err
+		for _, sc := range cfg.ERApi.Scopes {
+			r.Scopes = append(r.Scopes, bouncer.Scope{
+				Name:      sc.Name,
+				Scenarios: sc.Scenarios,
+				Group:     sc.Group,
+				PortGroup: sc.PortGroup,
+				Ports:     sc.Ports,
+				Protocol:  sc.Protocol,
+				Rulesets:  sc.Rulesets,
+				Rule:      sc.Rule,
+			})
 		}
-		group, err := ag.GetGroup(cfg.ERApi.Group)
-		if err != nil {
-			return err
+		for _, svc := range cfg.ERApi.Services {
+			r.Services = append(r.Services, bouncer.Service{
+				Scenarios: svc.Scenarios,
+				Port:      svc.Port,
+				Group:     svc.Group,
+				Rulesets:  svc.Rulesets,
+				Rule:      svc.Rule,
+			})
 		}
-		group.Reset()
-
-		var hasChanges bool
-
-	outer:
-		for {
-			select {
-			case <-gctx.Done():
-				break outer
-			case decision := <-bouncer.Stream:
-				for _, d := range decision.New {
-					ip := net.ParseIP(*d.Value)
-					if ip != nil &&
-						ip.To4() != nil &&
-						*d.Type == "ban" &&
-						group.Add(*d.Value) {
-						hasChanges = true
-					}
-				}
-				for _, d := range decision.Deleted {
-					ip := net.ParseIP(*d.Value)
-					if ip != nil &&
-						ip.To4() != nil && *d.Type == "ban" && group.Remove(*d.Value) {
-						hasChanges = true
-					}
-				}
-			case <-time.Tick(5 * time.Second):
-				if hasChanges {
-					log.Println("updating group")
-					hasChanges = false
-
-					setData, err := ag.GetSetData(group)
-					if err != nil {
-						return err
-					}
-					delData, err := ag.GetDeleteData(group)
-					if err != nil {
-						return err
-					}
-					log.Printf("old address count %v\n", len((*ag)[group.Name].Address))
-					log.Printf("new address count %v\n", len(group.Address))
-					for _, curDel := range delData {
-						_, err = erClient.Delete(curDel)
-						if err != nil {
-							return err
-						}
-					}
-					for _, curSet := range setData {
-						_, err := erClient.Set(curSet)
-						if err != nil {
-							return err
-						}
-					}
-
-					log.Println("group updated")
-					r, err := erClient.Get()
-					if err != nil {
-						return err
-					}
-					ag, err := xedgeos.NewAddressGroups(r)
-					if err != nil {
-						return err
-					}
-					log.Printf("Stored address count %v\n", len((*ag)[group.Name].Address))
-					os.Exit(0)
-				}
+		routers = append(routers, r)
+	}
+	if len(routers) == 0 {
+		return nil, errors.New("no routers configured")
+	}
 
-			}
+	return routers, nil
+}
+
+// serve runs the bouncer until ctx is cancelled.
+func serve(ctx context.Context, cfg *config.Config, _ []string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	desired, owned, err := loadDesired(cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.State.File != "" {
+		slog.Info("resumed state", "entries", desired.Len(), "file", cfg.State.File)
+	}
+	if cfg.ERApi.KeepUnmanaged && cfg.State.File == "" {
+		slog.Warn("no state file configured, treating all existing group entries as unmanaged")
+	}
+
+	routers, err := newRouters(cfg, owned)
+	if err != nil {
+		return err
+	}
+
+	stream := &csbouncer.StreamBouncer{
+		APIKey:         cfg.CSApi.Key,
+		APIUrl:         cfg.CSApi.Url,
+		TickerInterval: cfg.CSApi.UpdateFrequency.String(),
+	}
+
+	if err := stream.Init(); err != nil {
+		return err
+	}
+
+	eg, gctx := errgroup.WithContext(ctx)
+
+	if cfg.Metrics.Addr != "" {
+		srv := &http.Server{
+			Addr:    cfg.Metrics.Addr,
+			Handler: promhttp.Handler(),
 		}
+		eg.Go(func() error {
+			<-gctx.Done()
+			return srv.Shutdown(context.Background())
+		})
+		eg.Go(func() error {
+			if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		})
+	}
 
-		return nil
+	b := &bouncer.Bouncer{
+		Source:         bouncer.StreamSource{StreamBouncer: stream},
+		Routers:        routers,
+		Desired:        desired,
+		StateFile:      cfg.State.File,
+		ExpiryInterval: cfg.ERApi.ExpiryInterval,
+		Debounce:       cfg.Sync.Debounce,
+		MaxLatency:     cfg.Sync.MaxLatency,
+		MaxBatch:       cfg.Sync.MaxBatch,
+	}
+	eg.Go(func() error {
+		defer cancel()
+		return b.Run(gctx)
 	})
 
 	return eg.Wait()
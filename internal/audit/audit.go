@@ -0,0 +1,97 @@
+// Package audit keeps an append-only record of every address the bouncer
+// adds to or removes from a router.
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/jacobalberty/cs-edgeos-bouncer/internal/config"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Action is what was done to an address.
+type Action string
+
+const (
+	Ban   Action = "ban"
+	Unban Action = "unban"
+)
+
+// Result values for a Record.
+const (
+	ResultOK    = "ok"
+	ResultError = "error"
+)
+
+// Record is a single line of the audit log.
+type Record struct {
+	Time     time.Time `json:"time"`
+	Action   Action    `json:"action"`
+	Value    string    `json:"value"`
+	Router   string    `json:"router"`
+	Group    string    `json:"group"`
+	ID       int64     `json:"decision_id,omitempty"`
+	Origin   string    `json:"origin,omitempty"`
+	Scenario string    `json:"scenario,omitempty"`
+	Result   string    `json:"result"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Log writes records as JSON lines. It is safe for concurrent use so every
+// router can share one.
+type Log struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+}
+
+// New returns a Log writing to w.
+func New(w io.Writer) *Log {
+	return &Log{w: w, enc: json.NewEncoder(w)}
+}
+
+// Open returns a Log appending to the configured file, rotating it once it
+// reaches MaxSize.
+func Open(cfg config.AuditConfig) *Log {
+	return New(&lumberjack.Logger{
+		Filename:   cfg.File,
+		MaxSize:    cfg.MaxSize,
+		MaxBackups: cfg.MaxBackups,
+		MaxAge:     cfg.MaxAge,
+		Compress:   cfg.Compress,
+	})
+}
+
+// Write appends recs to the log. Records without a time are stamped with
+// the current time.
+func (l *Log) Write(recs ...Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for _, rec := range recs {
+		if rec.Time.IsZero() {
+			rec.Time = now
+		}
+		if err := l.enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close closes the underlying writer if it can be closed.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if c, ok := l.w.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jacobalberty/cs-edgeos-bouncer/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrite(t *testing.T) {
+	asrt := assert.New(t)
+
+	var buf bytes.Buffer
+	l := New(&buf)
+	at := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	asrt.NoError(l.Write(
+		Record{Time: at, Action: Ban, Value: "192.0.2.1", Router: "r1", Group: "CROWDSEC", ID: 7, Origin: "crowdsec", Scenario: "ssh-bf", Result: ResultOK},
+		Record{Action: Unban, Value: "192.0.2.2", Router: "r1", Group: "CROWDSEC", Result: ResultError, Error: "boom"},
+	))
+	asrt.NoError(l.Close())
+
+	var recs []Record
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		var rec Record
+		asrt.NoError(json.Unmarshal(sc.Bytes(), &rec))
+		recs = append(recs, rec)
+	}
+	asrt.Len(recs, 2)
+	asrt.True(recs[0].Time.Equal(at))
+	asrt.Equal(int64(7), recs[0].ID)
+	asrt.Equal("ssh-bf", recs[0].Scenario)
+	asrt.False(recs[1].Time.IsZero())
+	asrt.Equal(Unban, recs[1].Action)
+	asrt.Equal("boom", recs[1].Error)
+}
+
+func TestOpenAppends(t *testing.T) {
+	asrt := assert.New(t)
+	file := filepath.Join(t.TempDir(), "audit.log")
+
+	for _, v := range []string{"192.0.2.1", "192.0.2.2"} {
+		l := Open(config.AuditConfig{File: file, MaxSize: 1})
+		asrt.NoError(l.Write(Record{Action: Ban, Value: v, Result: ResultOK}))
+		asrt.NoError(l.Close())
+	}
+
+	bs, err := os.ReadFile(file)
+	asrt.NoError(err)
+	asrt.Equal(2, bytes.Count(bs, []byte("\n")))
+}
@@ -0,0 +1,120 @@
+package bouncer
+
+import (
+	"net/netip"
+	"time"
+
+	"github.com/jacobalberty/cs-edgeos-bouncer/internal/decisions"
+)
+
+// allowlist holds addresses and prefixes that must never be blocked on a
+// router, such as its DHCP clients and LAN subnets.
+type allowlist struct {
+	addrs    map[netip.Addr]bool
+	prefixes []netip.Prefix
+}
+
+// Contains reports whether the value is, or overlaps, an allowlisted address
+// or prefix.
+func (a allowlist) Contains(value string) bool {
+	if addr, err := netip.ParseAddr(value); err == nil {
+		if a.addrs[addr] {
+			return true
+		}
+		for _, p := range a.prefixes {
+			if p.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+
+	prefix, err := netip.ParsePrefix(value)
+	if err != nil {
+		return false
+	}
+	for addr := range a.addrs {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	for _, p := range a.prefixes {
+		if prefix.Overlaps(p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allowlistTTL is how long a loaded allowlist is used before the router's
+// DHCP leases and interfaces are fetched again.
+const allowlistTTL = 5 * time.Minute
+
+// loadAllowlist fetches the router's DHCP leases and the subnets of the
+// interfaces listed in LANInterfaces.
+func (r *Router) loadAllowlist() error {
+	al := allowlist{addrs: map[netip.Addr]bool{}}
+
+	if r.AllowDHCP {
+		leases, err := r.client.DHCPLeases()
+		if err != nil {
+			return err
+		}
+		for _, a := range leases.Addresses() {
+			if addr, err := netip.ParseAddr(a); err == nil {
+				al.addrs[addr] = true
+			}
+		}
+	}
+
+	if r.AllowLAN {
+		ifaces, err := r.client.Interfaces()
+		if err != nil {
+			return err
+		}
+		for _, name := range r.LANInterfaces {
+			iface, ok := ifaces[name]
+			if !ok {
+				r.log.Warn("LAN interface not found", "interface", name)
+				continue
+			}
+			for _, p := range iface.Prefixes() {
+				if !p.Addr().IsLinkLocalUnicast() {
+					al.prefixes = append(al.prefixes, p)
+				}
+			}
+		}
+	}
+
+	r.allow, r.allowLoaded = al, time.Now()
+
+	return nil
+}
+
+// allowed drops allowlisted entries. The allowlist is loaded on connect and
+// again once it is older than allowlistTTL so new DHCP clients are covered,
+// falling back to the previous one on error.
+func (r *Router) allowed(want []decisions.Entry) []decisions.Entry {
+	if !r.AllowDHCP && !r.AllowLAN {
+		return want
+	}
+	if time.Since(r.allowLoaded) > allowlistTTL {
+		if err := r.loadAllowlist(); err != nil {
+			r.log.Warn("unable to refresh allowlist", "err", err)
+		}
+	}
+
+	out := make([]decisions.Entry, 0, len(want))
+	for _, e := range want {
+		if r.allow.Contains(e.Value) {
+			continue
+		}
+		out = append(out, e)
+	}
+	if skipped := len(want) - len(out); skipped > 0 {
+		r.log.Info("allowlisted entries skipped", "entries", skipped)
+	}
+
+	return out
+}
@@ -0,0 +1,62 @@
+package bouncer
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/jacobalberty/cs-edgeos-bouncer/pkg/xedgeos/xedgeostest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllowlist(t *testing.T) {
+	asrt := assert.New(t)
+
+	al := allowlist{
+		addrs:    map[netip.Addr]bool{netip.MustParseAddr("10.10.0.5"): true},
+		prefixes: []netip.Prefix{netip.MustParsePrefix("192.168.16.0/22")},
+	}
+
+	asrt.True(al.Contains("10.10.0.5"))
+	asrt.True(al.Contains("192.168.17.20"))
+	asrt.False(al.Contains("203.0.113.9"))
+	asrt.True(al.Contains("10.0.0.0/8"))
+	asrt.True(al.Contains("192.168.0.0/16"))
+	asrt.False(al.Contains("198.51.100.0/24"))
+}
+
+func TestRouterAllowlist(t *testing.T) {
+	asrt := assert.New(t)
+	srv := xedgeostest.NewServer(map[string]any{
+		"firewall": map[string]any{
+			"group": map[string]any{
+				"address-group": map[string]any{"CROWDSEC": map[string]any{}},
+				"network-group": map[string]any{"CROWDSEC_NET": map[string]any{}},
+			},
+		},
+	})
+	defer srv.Close()
+	srv.SetData("interfaces", map[string]any{
+		"eth0":  map[string]any{"addresses": []string{"203.0.113.10/24"}},
+		"eth1":  map[string]any{"addresses": []string{"192.168.16.1/22", "fe80::1/64"}},
+		"vtun0": map[string]any{"addresses": []string{"10.8.0.1/24"}},
+	})
+	srv.SetData("dhcp_leases", map[string]any{
+		"dhcp-server-leases": map[string]any{
+			"GUEST": map[string]any{"10.10.0.5": map[string]any{"pool": "GUEST"}},
+		},
+	})
+
+	r := newTestRouter(t, srv)
+	r.AllowDHCP, r.AllowLAN = true, true
+	r.LANInterfaces = []string{"eth1"}
+
+	// Only the listed interface counts as LAN, not the VPN tunnel.
+	asrt.NoError(r.Sync(entries("192.168.17.20", "10.10.0.5", "10.8.0.7", "198.51.100.1")))
+	eventually(t, srv, addressPath, "10.8.0.7", "198.51.100.1")
+
+	// The allowlist loaded on connect is reused by later pushes.
+	data := srv.Requests(xedgeostest.EndpointData)
+	asrt.NoError(r.Sync(entries("192.168.17.20", "198.51.100.2")))
+	eventually(t, srv, addressPath, "198.51.100.2")
+	asrt.Equal(data, srv.Requests(xedgeostest.EndpointData))
+}
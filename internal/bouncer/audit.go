@@ -0,0 +1,56 @@
+package bouncer
+
+import (
+	"github.com/jacobalberty/cs-edgeos-bouncer/internal/audit"
+	"github.com/jacobalberty/cs-edgeos-bouncer/internal/decisions"
+)
+
+// manualOrigin is recorded for values added by hand through Apply.
+const manualOrigin = "manual"
+
+// record writes the outcome of applying action to values in group to the
+// audit log. entries supplies the decision behind each value, where known.
+// Values the router confirmed are remembered as owned, or forgotten for
+// unbans, so a later push removes them even if this one fails part way and
+// later unbans carry the decision that caused the ban.
+func (r *Router) record(action audit.Action, group string, values []string, entries map[string]decisions.Entry, err error) {
+	if len(values) == 0 {
+		return
+	}
+
+	if r.Audit != nil {
+		recs := make([]audit.Record, len(values))
+		for i, v := range values {
+			e := entries[v]
+			recs[i] = audit.Record{
+				Action:   action,
+				Value:    v,
+				Router:   r.Name,
+				Group:    group,
+				ID:       e.ID,
+				Origin:   e.Origin,
+				Scenario: e.Scenario,
+				Result:   audit.ResultOK,
+			}
+			if err != nil {
+				recs[i].Result, recs[i].Error = audit.ResultError, err.Error()
+			}
+		}
+		if err := r.Audit.Write(recs...); err != nil {
+			r.log.Error("unable to write audit log", "err", err)
+		}
+	}
+
+	if err != nil {
+		return
+	}
+	for _, v := range values {
+		if action == audit.Ban {
+			r.owner.Add(v)
+			r.entries[v] = entries[v]
+		} else {
+			r.owner.Remove(v)
+			delete(r.entries, v)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package bouncer
+
+import "time"
+
+// batcher coalesces changes to the desired set so a burst of decisions is
+// pushed to the routers once rather than piecemeal.
+//
+// A push is due once no change has arrived for debounce, once maxLatency has
+// passed since the first pending change, or as soon as maxBatch changes are
+// pending, whichever comes first.
+type batcher struct {
+	debounce   time.Duration
+	maxLatency time.Duration
+	maxBatch   int
+
+	pending int
+	// quiet fires after the debounce period and deadline after maxLatency.
+	quiet, deadline *time.Timer
+}
+
+func newBatcher(debounce, maxLatency time.Duration, maxBatch int) *batcher {
+	b := &batcher{
+		debounce:   debounce,
+		maxLatency: maxLatency,
+		maxBatch:   maxBatch,
+		quiet:      time.NewTimer(time.Hour),
+		deadline:   time.NewTimer(time.Hour),
+	}
+	b.quiet.Stop()
+	b.deadline.Stop()
+
+	return b
+}
+
+// add records n changes and reports whether they should be pushed straight
+// away.
+func (b *batcher) add(n int) bool {
+	if n <= 0 {
+		return false
+	}
+	if b.pending == 0 && b.maxLatency > 0 {
+		b.deadline.Reset(b.maxLatency)
+	}
+	b.pending += n
+
+	if b.debounce <= 0 || (b.maxBatch > 0 && b.pending >= b.maxBatch) {
+		return true
+	}
+	b.quiet.Reset(b.debounce)
+
+	return false
+}
+
+// due reports whether anything is pending. It is called when either timer
+// fires.
+func (b *batcher) due() bool {
+	return b.pending > 0
+}
+
+// flushed resets the batcher after the pending changes have been pushed.
+func (b *batcher) flushed() {
+	b.pending = 0
+	b.stop()
+}
+
+func (b *batcher) stop() {
+	b.quiet.Stop()
+	b.deadline.Stop()
+}
@@ -0,0 +1,58 @@
+package bouncer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatcherDebounce(t *testing.T) {
+	asrt := assert.New(t)
+
+	b := newBatcher(20*time.Millisecond, time.Hour, 0)
+	defer b.stop()
+	asrt.False(b.add(0))
+	asrt.False(b.due())
+
+	start := time.Now()
+	asrt.False(b.add(1))
+	time.Sleep(10 * time.Millisecond)
+	asrt.False(b.add(1))
+	<-b.quiet.C
+	asrt.True(b.due())
+	asrt.GreaterOrEqual(time.Since(start), 30*time.Millisecond)
+
+	b.flushed()
+	asrt.False(b.due())
+}
+
+func TestBatcherMaxLatency(t *testing.T) {
+	asrt := assert.New(t)
+
+	b := newBatcher(time.Hour, 30*time.Millisecond, 0)
+	defer b.stop()
+
+	start := time.Now()
+	for range 5 {
+		asrt.False(b.add(1))
+		time.Sleep(time.Millisecond)
+	}
+	<-b.deadline.C
+	asrt.True(b.due())
+	asrt.Less(time.Since(start), time.Second)
+}
+
+func TestBatcherMaxBatch(t *testing.T) {
+	asrt := assert.New(t)
+
+	b := newBatcher(time.Hour, time.Hour, 10)
+	defer b.stop()
+	asrt.False(b.add(9))
+	asrt.True(b.add(1))
+	b.flushed()
+	asrt.True(b.add(25))
+
+	// Without a debounce every change is pushed straight away.
+	asrt.True(newBatcher(0, 0, 0).add(1))
+}
@@ -0,0 +1,83 @@
+package bouncer
+
+import (
+	"time"
+
+	"github.com/jacobalberty/cs-edgeos-bouncer/pkg/xedgeos"
+)
+
+const defaultMaxBatchSize = 1000
+
+// batchSize picks how many values go in each set or delete request. Without
+// a target it stays fixed. With one it adapts to the router: a request that
+// fails or takes longer than the target to commit halves the size, while a
+// full batch committed in under half the target grows it by half, up to max.
+type batchSize struct {
+	size, max int
+	target    time.Duration
+}
+
+// newBatchSize returns a batchSize starting at size. Sizes below one mean
+// the defaults, and size is capped at max.
+func newBatchSize(size, max int, target time.Duration) *batchSize {
+	if size < 1 {
+		size = xedgeos.DefaultBatchSize
+	}
+	if max < 1 {
+		max = defaultMaxBatchSize
+	}
+
+	return &batchSize{
+		size:   min(size, max),
+		max:    max,
+		target: target,
+	}
+}
+
+// observe adjusts the size after a request of n values took d to commit, or
+// failed when err is set. A request that timed out may well have been too
+// large, so failures shrink the size like slow commits do.
+func (b *batchSize) observe(n int, d time.Duration, err error) {
+	switch {
+	case b.target <= 0:
+	case err != nil || d > b.target:
+		b.size = max(1, b.size/2)
+	case d < b.target/2 && n >= b.size:
+		b.size = min(b.max, b.size+max(1, b.size/2))
+	}
+}
+
+// batchSizer returns the router's batch size, creating it from the
+// configuration on first use.
+func (r *Router) batchSizer() *batchSize {
+	if r.batch == nil {
+		r.batch = newBatchSize(r.BatchSize, r.BatchMax, r.BatchTarget)
+	}
+
+	return r.batch
+}
+
+// send applies values to the router in batches, turning each into a payload
+// with data and sending it with do. done, if set, is told the outcome of
+// each batch.
+func (r *Router) send(values []string, data func([]string) map[string]any, do func(any) (xedgeos.Resp, error), done func([]string, error)) error {
+	b := r.batchSizer()
+	for len(values) > 0 {
+		n := min(b.size, len(values))
+
+		start := time.Now()
+		_, err := do(data(values[:n]))
+		if done != nil {
+			done(values[:n], err)
+		}
+		b.observe(n, time.Since(start), err)
+		routerBatchSize.WithLabelValues(r.Name).Set(float64(b.size))
+		if err != nil {
+			return err
+		}
+
+		values = values[n:]
+	}
+
+	return nil
+}
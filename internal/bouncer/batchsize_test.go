@@ -0,0 +1,109 @@
+package bouncer
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jacobalberty/cs-edgeos-bouncer/pkg/xedgeos/xedgeostest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchSize(t *testing.T) {
+	asrt := assert.New(t)
+
+	fixed := newBatchSize(0, 0, 0)
+	asrt.Equal(50, fixed.size)
+	fixed.observe(50, time.Hour, nil)
+	asrt.Equal(50, fixed.size)
+
+	b := newBatchSize(100, 200, time.Second)
+	b.observe(100, 2*time.Second, nil)
+	asrt.Equal(50, b.size)
+	// Partial batches say nothing about how large a batch could be.
+	b.observe(10, time.Millisecond, nil)
+	asrt.Equal(50, b.size)
+	b.observe(50, 100*time.Millisecond, nil)
+	asrt.Equal(75, b.size)
+	b.observe(75, 600*time.Millisecond, nil)
+	asrt.Equal(75, b.size)
+	for range 10 {
+		b.observe(b.size, time.Millisecond, nil)
+	}
+	asrt.Equal(200, b.size)
+	for range 20 {
+		b.observe(b.size, time.Minute, nil)
+	}
+	asrt.Equal(1, b.size)
+
+	// Failures only shrink an adaptive size.
+	fixed.observe(50, time.Millisecond, errors.New("timeout"))
+	asrt.Equal(50, fixed.size)
+	b = newBatchSize(100, 200, time.Second)
+	b.observe(100, time.Millisecond, errors.New("timeout"))
+	asrt.Equal(50, b.size)
+
+	// Out of range settings fall back to the defaults.
+	asrt.Equal(50, newBatchSize(-1, 0, 0).size)
+	asrt.Equal(1000, newBatchSize(-1, -1, 0).max)
+	asrt.Equal(100, newBatchSize(500, 100, 0).size)
+}
+
+func TestRouterBatchSize(t *testing.T) {
+	asrt := assert.New(t)
+	srv := xedgeostest.NewServer(map[string]any{
+		"firewall": map[string]any{
+			"group": map[string]any{
+				"address-group": map[string]any{"CROWDSEC": map[string]any{}},
+				"network-group": map[string]any{"CROWDSEC_NET": map[string]any{}},
+			},
+		},
+	})
+	defer srv.Close()
+
+	r := newTestRouter(t, srv)
+	r.BatchSize = 30
+
+	var values []string
+	for i := range 100 {
+		values = append(values, fmt.Sprintf("198.51.100.%d", i))
+	}
+	asrt.NoError(r.Sync(entries(values...)))
+	asrt.Equal(4, srv.Requests(xedgeostest.EndpointSet))
+	eventually(t, srv, addressPath, values...)
+
+	// Direct edits are batched the same way.
+	asrt.NoError(r.Apply(nil, values[:40]))
+	asrt.Equal(2, srv.Requests(xedgeostest.EndpointDelete))
+	eventually(t, srv, addressPath, values[40:]...)
+}
+
+func TestRouterBatchSizeFailure(t *testing.T) {
+	asrt := assert.New(t)
+	srv := xedgeostest.NewServer(map[string]any{
+		"firewall": map[string]any{
+			"group": map[string]any{
+				"address-group": map[string]any{"CROWDSEC": map[string]any{}},
+				"network-group": map[string]any{"CROWDSEC_NET": map[string]any{}},
+			},
+		},
+	})
+	defer srv.Close()
+
+	r := newTestRouter(t, srv)
+	r.BatchSize, r.BatchTarget = 40, time.Hour
+
+	var values []string
+	for i := range 40 {
+		values = append(values, fmt.Sprintf("198.51.100.%d", i))
+	}
+
+	// A failed request is retried with smaller batches.
+	srv.FailNext(xedgeostest.EndpointSet, 1)
+	asrt.Error(r.Sync(entries(values...)))
+	asrt.Equal(20, r.batchSizer().size)
+	asrt.NoError(r.Sync(entries(values...)))
+	asrt.Equal(3, srv.Requests(xedgeostest.EndpointSet))
+	eventually(t, srv, addressPath, values...)
+}
@@ -0,0 +1,121 @@
+package bouncer
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/jacobalberty/cs-edgeos-bouncer/pkg/xedgeos"
+)
+
+// Bootstrap describes the groups and drop rules that are created on routers
+// that don't have them yet. The address group's drop rule is inserted at Rule
+// and the network group's, if any, at Rule+1. IPv6 groups are created but
+// need rules in an ipv6-name ruleset, which is left to the administrator.
+type Bootstrap struct {
+	Description string
+	Rulesets    []string
+	Rule        int
+}
+
+// bootstrap creates the groups and drop rules if they are missing. Rules that
+// already drop the group are left alone, and an unrelated rule at the
+// configured number is an error rather than something to overwrite.
+func (r *Router) bootstrap() error {
+	res, err := r.client.GetPath("firewall")
+	if err != nil {
+		return err
+	}
+
+	ag, err := xedgeos.NewAddressGroups(res)
+	if err != nil {
+		return err
+	}
+	rules := map[int]xedgeos.RuleGroup{}
+	if r.Group != "" {
+		if _, err := ag.GetGroup(r.Group); err != nil {
+			r.log.Info("creating group", "group", r.Group)
+			if _, err := r.client.Set(ag.GetCreateData(&xedgeos.AddressGroup{
+				Name:        r.Group,
+				Description: r.Bootstrap.Description,
+			})); err != nil {
+				return err
+			}
+		}
+		rules[r.Bootstrap.Rule] = xedgeos.RuleGroup{AddressGroup: r.Group}
+	}
+	if r.NetworkGroup != "" {
+		if err := r.bootstrapNetworkGroup(res, xedgeos.NetworkGroupType, r.NetworkGroup); err != nil {
+			return err
+		}
+		rules[r.Bootstrap.Rule+1] = xedgeos.RuleGroup{NetworkGroup: r.NetworkGroup}
+	}
+	if r.IPv6NetworkGroup != "" {
+		if err := r.bootstrapNetworkGroup(res, xedgeos.IPv6NetworkGroupType, r.IPv6NetworkGroup); err != nil {
+			return err
+		}
+	}
+
+	rulesets, err := xedgeos.NewRulesets(res)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range r.Bootstrap.Rulesets {
+		rs, err := rulesets.GetRuleset(name)
+		if err != nil {
+			return err
+		}
+
+		for _, n := range slices.Sorted(maps.Keys(rules)) {
+			source := rules[n]
+			if rule, ok := rs.Rules[n]; ok {
+				// The rule may have been swapped over to the standby group.
+				swapped := source.AddressGroup != "" && r.SwapGroup != "" &&
+					rule.DropsSource(xedgeos.RuleGroup{AddressGroup: r.SwapGroup})
+				if !rule.DropsSource(source) && !swapped {
+					return fmt.Errorf("rule %d in %s is already in use", n, name)
+				}
+				continue
+			}
+
+			r.log.Info("adding rule", "ruleset", name, "rule", n)
+			data, err := xedgeos.DropRule(source, r.Bootstrap.Description).GetSetData(name, n)
+			if err != nil {
+				return err
+			}
+			if _, err := r.client.Set(data); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *Router) bootstrapNetworkGroup(res xedgeos.Resp, t xedgeos.GroupType, name string) error {
+	var (
+		ng  *xedgeos.NetworkGroupCollection
+		err error
+	)
+	if t == xedgeos.IPv6NetworkGroupType {
+		ng, err = xedgeos.NewIPv6NetworkGroups(res)
+	} else {
+		ng, err = xedgeos.NewNetworkGroups(res)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := ng.GetGroup(name); err == nil {
+		return nil
+	}
+
+	r.log.Info("creating group", "group", name)
+	_, err = r.client.Set(ng.GetCreateData(&xedgeos.NetworkGroup{
+		Name:        name,
+		Type:        t,
+		Description: r.Bootstrap.Description,
+	}))
+
+	return err
+}
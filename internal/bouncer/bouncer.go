@@ -0,0 +1,174 @@
+package bouncer
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jacobalberty/cs-edgeos-bouncer/internal/decisions"
+	"golang.org/x/sync/errgroup"
+)
+
+const defaultExpiryInterval = time.Minute
+
+// Bouncer feeds decisions from a Source into the desired set and hands the
+// result to every router.
+type Bouncer struct {
+	Source  Source
+	Routers []*Router
+	Desired *decisions.Set
+
+	// StateFile, when set, is where the desired set is saved whenever a
+	// router finishes a sync.
+	StateFile string
+	// ExpiryInterval is how often decisions are expired locally.
+	ExpiryInterval time.Duration
+
+	// Debounce, MaxLatency and MaxBatch decide when pending changes are
+	// handed to the routers: after Debounce without further changes, no
+	// later than MaxLatency after the first change, or as soon as MaxBatch
+	// changes are pending. A zero Debounce hands over every change straight
+	// away.
+	Debounce   time.Duration
+	MaxLatency time.Duration
+	MaxBatch   int
+}
+
+// Handles reports whether any router has a group for the value.
+func (b *Bouncer) Handles(v string) bool {
+	k := KindOf(v)
+	for _, r := range b.Routers {
+		if r.Handles(k) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Run runs the source and routers and keeps them in step until ctx is
+// cancelled or the source gives up.
+func (b *Bouncer) Run(ctx context.Context) error {
+	if len(b.Routers) == 0 {
+		return errors.New("no routers configured")
+	}
+
+	eg, gctx := errgroup.WithContext(ctx)
+
+	sourceDone := make(chan struct{})
+	eg.Go(func() error {
+		defer close(sourceDone)
+		b.Source.Run(gctx)
+
+		return nil
+	})
+
+	synced := make(chan string)
+	for _, r := range b.Routers {
+		eg.Go(func() error {
+			r.Run(gctx, synced)
+			return nil
+		})
+	}
+
+	eg.Go(func() error {
+		err := b.loop(gctx, synced)
+
+		// The source may be blocked handing over decisions, keep draining
+		// until it notices we're done.
+		for {
+			select {
+			case _, ok := <-b.Source.Decisions():
+				if !ok {
+					return err
+				}
+			case <-sourceDone:
+				return err
+			}
+		}
+	})
+
+	return eg.Wait()
+}
+
+func (b *Bouncer) loop(ctx context.Context, synced <-chan string) error {
+	var (
+		batch  = newBatcher(b.Debounce, b.MaxLatency, b.MaxBatch)
+		expiry = time.NewTicker(cmp.Or(b.ExpiryInterval, defaultExpiryInterval))
+	)
+	defer batch.stop()
+	defer expiry.Stop()
+
+	flush := func() {
+		batch.flushed()
+
+		want := b.Desired.Entries()
+		for _, r := range b.Routers {
+			r.Update(want)
+		}
+	}
+
+	if batch.add(b.Desired.Len()) {
+		flush()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case decision, ok := <-b.Source.Decisions():
+			if !ok {
+				return errors.New("decision stream closed")
+			}
+			var (
+				now     = time.Now()
+				changed int
+			)
+			for _, d := range decision.New {
+				e := decisions.FromModel(d, now)
+				if b.Handles(e.Value) &&
+					*d.Type == "ban" &&
+					b.Desired.Add(e) {
+					changed++
+				}
+			}
+			for _, d := range decision.Deleted {
+				e := decisions.FromModel(d, now)
+				if b.Handles(e.Value) &&
+					*d.Type == "ban" && b.Desired.Remove(e.Value, e.ID) {
+					changed++
+				}
+			}
+			if batch.add(changed) {
+				flush()
+			}
+		case now := <-expiry.C:
+			// LAPI may be unreachable, so don't rely on it to tell us
+			// about expired decisions. Anything still valid is re-added
+			// when the stream next delivers it.
+			if expired := b.Desired.Expire(now); len(expired) > 0 {
+				slog.Info("decisions expired locally", "entries", len(expired))
+				if batch.add(len(expired)) {
+					flush()
+				}
+			}
+		case name := <-synced:
+			slog.Info("router in sync", "router", name)
+			if b.StateFile != "" {
+				if err := b.Desired.Save(b.StateFile); err != nil {
+					slog.Error("unable to save state", "file", b.StateFile, "err", err)
+				}
+			}
+		case <-batch.quiet.C:
+			if batch.due() {
+				flush()
+			}
+		case <-batch.deadline.C:
+			if batch.due() {
+				flush()
+			}
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package bouncer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	csbouncer "github.com/crowdsecurity/go-cs-bouncer"
+	"github.com/jacobalberty/cs-edgeos-bouncer/internal/decisions"
+	"github.com/jacobalberty/cs-edgeos-bouncer/internal/lapitest"
+	"github.com/jacobalberty/cs-edgeos-bouncer/pkg/xedgeos"
+	"github.com/jacobalberty/cs-edgeos-bouncer/pkg/xedgeos/xedgeostest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// testBouncer runs a bouncer between a fake LAPI and a fake router until the
+// test ends.
+func testBouncer(t *testing.T) (*lapitest.Server, *xedgeostest.Server) {
+	lapi := lapitest.NewServer("key")
+	t.Cleanup(lapi.Close)
+	srv := groupServer(t)
+	runBouncer(t, lapi, newTestRouter(t, srv))
+
+	return lapi, srv
+}
+
+// groupServer starts a fake router with empty groups.
+func groupServer(t *testing.T) *xedgeostest.Server {
+	srv := xedgeostest.NewServer(map[string]any{
+		"firewall": map[string]any{
+			"group": map[string]any{
+				"address-group": map[string]any{"CROWDSEC": map[string]any{}},
+				"network-group": map[string]any{"CROWDSEC_NET": map[string]any{}},
+			},
+		},
+	})
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// runBouncer runs a bouncer feeding the routers from lapi until the test
+// ends.
+func runBouncer(t *testing.T, lapi *lapitest.Server, routers ...*Router) {
+	stream := &csbouncer.StreamBouncer{
+		APIKey:         "key",
+		APIUrl:         lapi.URL,
+		TickerInterval: "10ms",
+	}
+	assert.NoError(t, stream.Init())
+
+	b := &Bouncer{
+		Source:   StreamSource{StreamBouncer: stream},
+		Routers:  routers,
+		Desired:  decisions.NewSet(),
+		Debounce: 10 * time.Millisecond,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- b.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-done)
+	})
+}
+
+const (
+	addressPath = "firewall group address-group CROWDSEC address"
+	networkPath = "firewall group network-group CROWDSEC_NET network"
+)
+
+// eventually waits for the router's configuration at path to hold exactly
+// want.
+func eventually(t *testing.T, srv *xedgeostest.Server, path string, want ...string) {
+	t.Helper()
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		n, _ := srv.Lookup(xedgeos.ParsePath(path)...)
+		assert.ElementsMatch(c, want, n.Values())
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestBouncerAddDelete(t *testing.T) {
+	lapi, srv := testBouncer(t)
+
+	lapi.Add(lapitest.Ban("198.51.100.1"), lapitest.Ban("203.0.113.0/24"))
+	eventually(t, srv, addressPath, "198.51.100.1")
+	eventually(t, srv, networkPath, "203.0.113.0/24")
+
+	lapi.Add(lapitest.Ban("198.51.100.2"))
+	lapi.Delete("198.51.100.1", "203.0.113.0/24")
+	eventually(t, srv, addressPath, "198.51.100.2")
+	eventually(t, srv, networkPath)
+}
+
+func TestBouncerIgnored(t *testing.T) {
+	lapi, srv := testBouncer(t)
+
+	lapi.Add(
+		// No router has an IPv6 network group.
+		lapitest.Ban("2001:db8::1"),
+		lapitest.Ban("2001:db8::/64"),
+		lapitest.Decision("198.51.100.3", "captcha"),
+		lapitest.Ban("198.51.100.4"),
+	)
+	eventually(t, srv, addressPath, "198.51.100.4")
+	eventually(t, srv, networkPath)
+	_, ok := srv.Lookup("firewall", "group", "ipv6-network-group")
+	assert.False(t, ok)
+}
+
+func TestBouncerIPv6(t *testing.T) {
+	lapi := lapitest.NewServer("key")
+	defer lapi.Close()
+	srv := xedgeostest.NewServer(map[string]any{
+		"firewall": map[string]any{
+			"group": map[string]any{
+				"ipv6-network-group": map[string]any{"CROWDSEC_V6": map[string]any{}},
+			},
+		},
+	})
+	defer srv.Close()
+
+	r := newTestRouter(t, srv)
+	r.Group, r.NetworkGroup, r.IPv6NetworkGroup = "", "", "CROWDSEC_V6"
+	runBouncer(t, lapi, r)
+
+	// Single addresses are pushed as /128 prefixes, like imported ones.
+	const path = "firewall group ipv6-network-group CROWDSEC_V6 network"
+	lapi.Add(lapitest.Ban("2001:db8::1"), lapitest.Ban("2001:db8:1::/48"))
+	eventually(t, srv, path, "2001:db8::1/128", "2001:db8:1::/48")
+
+	lapi.Delete("2001:db8::1")
+	eventually(t, srv, path, "2001:db8:1::/48")
+}
+
+func TestBouncerDuplicate(t *testing.T) {
+	lapi, srv := testBouncer(t)
+
+	lapi.Add(lapitest.Ban("198.51.100.5"), lapitest.Ban("198.51.100.5"))
+	eventually(t, srv, addressPath, "198.51.100.5")
+
+	polls := lapi.Polls()
+	lapi.Add(lapitest.Ban("198.51.100.5"))
+	assert.Eventually(t, func() bool { return lapi.Polls() > polls+2 }, 5*time.Second, 10*time.Millisecond)
+	eventually(t, srv, addressPath, "198.51.100.5")
+}
+
+func TestBouncerOverlapping(t *testing.T) {
+	lapi, srv := testBouncer(t)
+
+	local, community := lapitest.Ban("198.51.100.6"), lapitest.Ban("198.51.100.6")
+	origin, duration := "CAPI", "168h"
+	community.Origin, community.Duration = &origin, &duration
+	lapi.Add(local, community)
+	eventually(t, srv, addressPath, "198.51.100.6")
+
+	// The community ban still holds after the local one is deleted.
+	polls := lapi.Polls()
+	lapi.DeleteID(local.ID)
+	assert.Eventually(t, func() bool { return lapi.Polls() > polls+2 }, 5*time.Second, 10*time.Millisecond)
+	eventually(t, srv, addressPath, "198.51.100.6")
+
+	lapi.DeleteID(community.ID)
+	eventually(t, srv, addressPath)
+}
+
+func TestBouncerRouters(t *testing.T) {
+	asrt := assert.New(t)
+	defer func(lo, hi time.Duration) { minBackoff, maxBackoff = lo, hi }(minBackoff, maxBackoff)
+	minBackoff, maxBackoff = 10*time.Millisecond, 20*time.Millisecond
+
+	lapi := lapitest.NewServer("key")
+	defer lapi.Close()
+	primary, backup := groupServer(t), groupServer(t)
+	backup.FailNext(xedgeostest.EndpointLogin, 1<<20)
+
+	a, b := newTestRouter(t, primary), newTestRouter(t, backup)
+	a.Name, b.Name = "primary", "backup"
+	runBouncer(t, lapi, a, b)
+
+	// The healthy router is kept in sync while the other keeps failing.
+	lapi.Add(lapitest.Ban("198.51.100.1"))
+	eventually(t, primary, addressPath, "198.51.100.1")
+	asrt.Eventually(func() bool {
+		return testutil.ToFloat64(routerUp.WithLabelValues("primary")) == 1 &&
+			testutil.ToFloat64(routerUp.WithLabelValues("backup")) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	n, _ := backup.Lookup(xedgeos.ParsePath(addressPath)...)
+	asrt.Empty(n.Values())
+
+	// Once it is reachable again it catches up.
+	backup.Recover(xedgeostest.EndpointLogin)
+	eventually(t, backup, addressPath, "198.51.100.1")
+	asrt.Eventually(func() bool {
+		return testutil.ToFloat64(routerUp.WithLabelValues("backup")) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// An expired session on one router doesn't hold up either.
+	backup.ExpireSessions()
+	lapi.Add(lapitest.Ban("198.51.100.2"))
+	eventually(t, primary, addressPath, "198.51.100.1", "198.51.100.2")
+	eventually(t, backup, addressPath, "198.51.100.1", "198.51.100.2")
+	asrt.Positive(testutil.ToFloat64(routerSyncs.WithLabelValues("backup", "success")))
+}
+
+func TestBouncerSourceClosed(t *testing.T) {
+	lapi := lapitest.NewServer("key")
+	defer lapi.Close()
+	srv := xedgeostest.NewServer(nil)
+	defer srv.Close()
+
+	stream := &csbouncer.StreamBouncer{
+		APIKey:         "wrong",
+		APIUrl:         lapi.URL,
+		TickerInterval: "10ms",
+	}
+	assert.NoError(t, stream.Init())
+
+	b := &Bouncer{
+		Source:  StreamSource{StreamBouncer: stream},
+		Routers: []*Router{newTestRouter(t, srv)},
+		Desired: decisions.NewSet(),
+	}
+	assert.Error(t, b.Run(context.Background()))
+}
@@ -0,0 +1,52 @@
+package bouncer
+
+import "net/netip"
+
+// Kind is the sort of firewall group a decision value belongs in.
+type Kind int
+
+const (
+	KindUnsupported Kind = iota
+	KindAddress
+	KindNetwork
+	KindIPv6Network
+)
+
+// KindOf classifies a decision value. Single IPv4 addresses go in the address
+// group while prefixes go in the network groups. Single IPv6 addresses go in
+// the IPv6 network group, as /128 prefixes once normalized.
+func KindOf(value string) Kind {
+	if addr, err := netip.ParseAddr(value); err == nil {
+		switch {
+		case addr.Is4():
+			return KindAddress
+		case addr.Zone() == "":
+			return KindIPv6Network
+		default:
+			return KindUnsupported
+		}
+	}
+
+	prefix, err := netip.ParsePrefix(value)
+	if err != nil {
+		return KindUnsupported
+	}
+	if prefix.Addr().Is4() {
+		return KindNetwork
+	}
+
+	return KindIPv6Network
+}
+
+// splitKinds sorts values by the kind of group they belong in, dropping
+// anything unsupported.
+func splitKinds(values []string) map[Kind][]string {
+	out := map[Kind][]string{}
+	for _, v := range values {
+		if k := KindOf(v); k != KindUnsupported {
+			out[k] = append(out[k], v)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,19 @@
+package bouncer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKindOf(t *testing.T) {
+	asrt := assert.New(t)
+
+	asrt.Equal(KindAddress, KindOf("192.0.2.1"))
+	asrt.Equal(KindNetwork, KindOf("192.0.2.0/24"))
+	asrt.Equal(KindIPv6Network, KindOf("2001:db8::/32"))
+	asrt.Equal(KindIPv6Network, KindOf("2001:db8::1"))
+	asrt.Equal(KindIPv6Network, KindOf("2001:db8::1/128"))
+	asrt.Equal(KindUnsupported, KindOf("fe80::1%eth0"))
+	asrt.Equal(KindUnsupported, KindOf("example.com"))
+}
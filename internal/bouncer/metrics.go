@@ -0,0 +1,34 @@
+package bouncer
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	routerUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "edgeos_bouncer_router_up",
+		Help: "Whether the last attempt to talk to the router succeeded",
+	}, []string{"router"})
+
+	routerInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "edgeos_bouncer_router_info",
+		Help: "The model and firmware version of the router",
+	}, []string{"router", "model", "version"})
+
+	routerSyncs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "edgeos_bouncer_router_syncs_total",
+		Help: "The total number of group updates pushed to the router",
+	}, []string{"router", "result"})
+
+	routerGroupEntries = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "edgeos_bouncer_router_group_entries",
+		Help: "The number of entries in the router's address group",
+	}, []string{"router", "group"})
+
+	routerBatchSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "edgeos_bouncer_router_batch_size",
+		Help: "The number of values sent to the router per request",
+	}, []string{"router"})
+)
+
+func init() {
+	prometheus.MustRegister(routerUp, routerInfo, routerSyncs, routerGroupEntries, routerBatchSize)
+}
@@ -0,0 +1,227 @@
+package bouncer
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/jacobalberty/cs-edgeos-bouncer/internal/audit"
+	"github.com/jacobalberty/cs-edgeos-bouncer/internal/decisions"
+	"github.com/jacobalberty/cs-edgeos-bouncer/pkg/xedgeos"
+)
+
+// GroupStatus is the number of entries in one of a router's groups.
+type GroupStatus struct {
+	Name    string
+	Entries int
+}
+
+// Status is a snapshot of a router and the groups the bouncer manages on it.
+type Status struct {
+	Model, Version string
+	Groups         []GroupStatus
+}
+
+// GroupDiff lists the values a sync would add to and remove from a group.
+type GroupDiff struct {
+	Name        string
+	Add, Remove []string
+}
+
+// ensureConnected logs in, creates whatever is missing and loads the groups
+// unless that has already been done.
+func (r *Router) ensureConnected() error {
+	if r.ag != nil && !r.readOnly {
+		return nil
+	}
+
+	return r.connect()
+}
+
+// ensureInspected logs in and loads the groups without changing the router,
+// unless the groups are already loaded.
+func (r *Router) ensureInspected() error {
+	if r.ag != nil {
+		return nil
+	}
+
+	return r.inspect()
+}
+
+// Status reports the size of every managed group, without changing anything
+// on the router.
+func (r *Router) Status() (Status, error) {
+	if err := r.ensureInspected(); err != nil {
+		return Status{}, err
+	}
+
+	var st Status
+	if info, err := r.client.SystemInfo(); err == nil {
+		st.Model, st.Version = info.Model, info.Version
+	}
+	if group := r.activeGroup(); group != "" {
+		st.Groups = append(st.Groups, GroupStatus{group, len((*r.ag)[group].Address)})
+	}
+	for _, s := range r.allScopes() {
+		st.Groups = append(st.Groups, GroupStatus{s.Group, len((*r.ag)[s.Group].Address)})
+	}
+	if r.NetworkGroup != "" {
+		st.Groups = append(st.Groups, GroupStatus{r.NetworkGroup, len((*r.ng)[r.NetworkGroup].Network)})
+	}
+	if r.IPv6NetworkGroup != "" {
+		st.Groups = append(st.Groups, GroupStatus{r.IPv6NetworkGroup, len((*r.v6)[r.IPv6NetworkGroup].Network)})
+	}
+
+	return st, nil
+}
+
+// Diff reports what syncing want would change on the router, without
+// changing anything. Groups that are already in line are left out.
+func (r *Router) Diff(want []decisions.Entry) ([]GroupDiff, error) {
+	if err := r.ensureInspected(); err != nil {
+		return nil, err
+	}
+	changes, _, _, err := r.plan(want)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []GroupDiff
+	for _, c := range changes {
+		if len(c.add) > 0 || len(c.remove) > 0 {
+			out = append(out, GroupDiff{Name: c.name, Add: c.add, Remove: c.remove})
+		}
+	}
+
+	return out, nil
+}
+
+// Values returns every value in the router's managed groups, sorted, without
+// changing anything on the router.
+func (r *Router) Values() ([]string, error) {
+	if err := r.ensureInspected(); err != nil {
+		return nil, err
+	}
+
+	var out []string
+	if group := r.activeGroup(); group != "" {
+		out = append(out, (*r.ag)[group].Address...)
+	}
+	for _, s := range r.allScopes() {
+		out = append(out, (*r.ag)[s.Group].Address...)
+	}
+	if r.NetworkGroup != "" {
+		out = append(out, (*r.ng)[r.NetworkGroup].Network...)
+	}
+	if r.IPv6NetworkGroup != "" {
+		out = append(out, (*r.v6)[r.IPv6NetworkGroup].Network...)
+	}
+	slices.Sort(out)
+
+	return slices.Compact(out), nil
+}
+
+// Sync brings the router in line with want straight away. Syncing nothing
+// flushes every managed entry, leaving unmanaged ones when KeepUnmanaged is
+// set.
+func (r *Router) Sync(want []decisions.Entry) error {
+	return r.step(want, true)
+}
+
+// Apply adds and removes values in the router's main groups directly,
+// bypassing the desired set. Values are normalized first, so IPv6 addresses
+// are applied as /128 prefixes. Values the router has no group for are an
+// error. Changes are audited with a manual origin.
+func (r *Router) Apply(add, remove []string) error {
+	if err := r.ensureConnected(); err != nil {
+		return err
+	}
+	add, err := normalized(add)
+	if err != nil {
+		return err
+	}
+	remove, err = normalized(remove)
+	if err != nil {
+		return err
+	}
+	for _, v := range slices.Concat(add, remove) {
+		if !r.Handles(KindOf(v)) {
+			return fmt.Errorf("router %s has no group for %s", r.Name, v)
+		}
+	}
+
+	adds, removes := splitKinds(add), splitKinds(remove)
+
+	var changes []groupChange
+	if r.Group != "" {
+		group, err := r.ag.GetGroup(r.activeGroup())
+		if err != nil {
+			return err
+		}
+		c := groupChange{name: group.Name, set: group.SetData, del: group.DeleteData}
+		c.add, c.remove = edited(group.Address, adds[KindAddress], removes[KindAddress])
+		changes = append(changes, c)
+	}
+	for _, g := range []struct {
+		coll *xedgeos.NetworkGroupCollection
+		name string
+		kind Kind
+	}{
+		{r.ng, r.NetworkGroup, KindNetwork},
+		{r.v6, r.IPv6NetworkGroup, KindIPv6Network},
+	} {
+		if g.name == "" {
+			continue
+		}
+		group, err := g.coll.GetGroup(g.name)
+		if err != nil {
+			return err
+		}
+		c := groupChange{name: group.Name, set: group.SetData, del: group.DeleteData}
+		c.add, c.remove = edited(group.Network, adds[g.kind], removes[g.kind])
+		changes = append(changes, c)
+	}
+
+	manual := make(map[string]decisions.Entry, len(add))
+	for _, v := range add {
+		manual[v] = decisions.Entry{Value: v, Origin: manualOrigin}
+	}
+	for _, c := range changes {
+		unban := func(values []string, err error) { r.record(audit.Unban, c.name, values, r.entries, err) }
+		if err := r.send(c.remove, c.del, r.client.Delete, unban); err != nil {
+			r.ag = nil
+			return err
+		}
+		ban := func(values []string, err error) { r.record(audit.Ban, c.name, values, manual, err) }
+		if err := r.send(c.add, c.set, r.client.Set, ban); err != nil {
+			r.ag = nil
+			return err
+		}
+	}
+
+	return r.refresh()
+}
+
+func normalized(values []string) ([]string, error) {
+	out := make([]string, len(values))
+	for i, v := range values {
+		n, err := decisions.Normalize(v)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = n
+	}
+
+	return out, nil
+}
+
+// edited returns the values that adding add to and removing remove from the
+// sorted members in current actually change. current is left untouched.
+func edited(current, add, remove []string) (added, removed []string) {
+	drop := make(map[string]bool, len(remove))
+	for _, v := range remove {
+		drop[v] = true
+	}
+	kept := slices.DeleteFunc(slices.Clone(current), func(v string) bool { return drop[v] })
+
+	return xedgeos.Diff(current, decisions.Merge(kept, add))
+}
@@ -0,0 +1,112 @@
+package bouncer
+
+import (
+	"testing"
+
+	"github.com/jacobalberty/cs-edgeos-bouncer/pkg/xedgeos"
+	"github.com/jacobalberty/cs-edgeos-bouncer/pkg/xedgeos/xedgeostest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouterOps(t *testing.T) {
+	asrt := assert.New(t)
+	srv := xedgeostest.NewServer(map[string]any{
+		"firewall": map[string]any{
+			"group": map[string]any{
+				"address-group": map[string]any{
+					"CROWDSEC": map[string]any{"address": []string{"192.0.2.1"}},
+				},
+				"network-group": map[string]any{"CROWDSEC_NET": map[string]any{}},
+			},
+		},
+	})
+	defer srv.Close()
+	srv.SetData("sys_info", map[string]string{"model": "ER-X", "sw_ver": "v2.0.9"})
+
+	r := newTestRouter(t, srv)
+	r.KeepUnmanaged = true
+
+	st, err := r.Status()
+	asrt.NoError(err)
+	asrt.Equal("ER-X", st.Model)
+	asrt.Equal([]GroupStatus{{"CROWDSEC", 1}, {"CROWDSEC_NET", 0}}, st.Groups)
+
+	diffs, err := r.Diff(entries("198.51.100.1", "203.0.113.0/24"))
+	asrt.NoError(err)
+	asrt.Equal([]GroupDiff{
+		{Name: "CROWDSEC", Add: []string{"198.51.100.1"}},
+		{Name: "CROWDSEC_NET", Add: []string{"203.0.113.0/24"}},
+	}, diffs)
+	eventually(t, srv, addressPath, "192.0.2.1")
+
+	asrt.NoError(r.Apply([]string{"198.51.100.9", "203.0.113.0/24"}, nil))
+	eventually(t, srv, addressPath, "192.0.2.1", "198.51.100.9")
+	eventually(t, srv, networkPath, "203.0.113.0/24")
+	asrt.NoError(r.Apply(nil, []string{"198.51.100.9"}))
+	eventually(t, srv, addressPath, "192.0.2.1")
+
+	// Removing from the middle of the group leaves its neighbours.
+	asrt.NoError(r.Apply([]string{"192.0.2.2", "192.0.2.3"}, nil))
+	eventually(t, srv, addressPath, "192.0.2.1", "192.0.2.2", "192.0.2.3")
+	asrt.NoError(r.Apply(nil, []string{"192.0.2.2"}))
+	eventually(t, srv, addressPath, "192.0.2.1", "192.0.2.3")
+	asrt.NoError(r.Apply(nil, []string{"192.0.2.3"}))
+	eventually(t, srv, addressPath, "192.0.2.1")
+	asrt.Error(r.Apply([]string{"2001:db8::/64"}, nil))
+	asrt.Error(r.Apply([]string{"2001:db8::1"}, nil))
+	asrt.Error(r.Apply([]string{"example.com"}, nil))
+	// IPv4-mapped addresses are applied as plain IPv4.
+	asrt.NoError(r.Apply([]string{"::ffff:192.0.2.4"}, nil))
+	eventually(t, srv, addressPath, "192.0.2.1", "192.0.2.4")
+	asrt.NoError(r.Apply(nil, []string{"192.0.2.4"}))
+
+	// Flushing removes only what the bouncer pushed.
+	asrt.NoError(r.Sync(entries("198.51.100.1")))
+	eventually(t, srv, addressPath, "192.0.2.1", "198.51.100.1")
+	asrt.NoError(r.Sync(nil))
+	eventually(t, srv, addressPath, "192.0.2.1")
+}
+
+func TestRouterOpsReadOnly(t *testing.T) {
+	asrt := assert.New(t)
+	srv := xedgeostest.NewServer(map[string]any{
+		"firewall": map[string]any{
+			"group": map[string]any{
+				"address-group": map[string]any{
+					"CROWDSEC":         map[string]any{"address": []string{"192.0.2.1"}},
+					"CROWDSEC_PF_2222": map[string]any{"address": []string{"192.0.2.5"}},
+				},
+				"network-group": map[string]any{"CROWDSEC_NET": map[string]any{}},
+			},
+			"name": map[string]any{"WAN_IN": map[string]any{"default-action": "drop"}},
+		},
+	})
+	defer srv.Close()
+	srv.SetFeature(xedgeos.PortForwarding, xedgeos.PortForwards{})
+
+	// Everything here would be provisioned on connect: missing bootstrap
+	// rules, a missing swap group and the group of a removed forward.
+	r := newTestRouter(t, srv)
+	r.Bootstrap = &Bootstrap{Description: "CrowdSec bouncer", Rulesets: []string{"WAN_IN"}, Rule: 1}
+	r.SwapGroup = "CROWDSEC_B"
+	r.Services = []Service{{Scenarios: []string{"ssh-bf"}, Port: "2222", Rule: 20}}
+
+	_, err := r.Status()
+	asrt.NoError(err)
+	_, err = r.Diff(entries("198.51.100.1"))
+	asrt.NoError(err)
+	_, err = r.Values()
+	asrt.NoError(err)
+	for _, endpoint := range []string{xedgeostest.EndpointSet, xedgeostest.EndpointDelete, xedgeostest.EndpointBatch} {
+		asrt.Zero(srv.Requests(endpoint), endpoint)
+	}
+	eventually(t, srv, servicePath, "192.0.2.5")
+
+	// A sync after inspecting still provisions.
+	asrt.NoError(r.Sync(entries("198.51.100.1")))
+	eventually(t, srv, servicePath)
+	_, ok := srv.Lookup("firewall", "group", "address-group", "CROWDSEC_B")
+	asrt.True(ok)
+	_, ok = srv.Lookup("firewall", "name", "WAN_IN", "rule", "1")
+	asrt.True(ok)
+}
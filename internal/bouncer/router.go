@@ -0,0 +1,646 @@
+// Package bouncer applies the bouncer's desired set of addresses to one or
+// more EdgeOS routers.
+package bouncer
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/jacobalberty/cs-edgeos-bouncer/internal/audit"
+	"github.com/jacobalberty/cs-edgeos-bouncer/internal/config"
+	"github.com/jacobalberty/cs-edgeos-bouncer/internal/decisions"
+	"github.com/jacobalberty/cs-edgeos-bouncer/pkg/xedgeos"
+)
+
+// minBackoff and maxBackoff bound the delay before a failing router is
+// retried.
+var (
+	minBackoff = 5 * time.Second
+	maxBackoff = 5 * time.Minute
+)
+
+// Router keeps the firewall groups of a single EdgeOS device in line with the
+// addresses it is handed. Each Router retries independently so one
+// unreachable device does not hold up the others.
+type Router struct {
+	Name             string
+	Group            string
+	NetworkGroup     string
+	IPv6NetworkGroup string
+	KeepUnmanaged    bool
+
+	// Bootstrap, when set, creates the group and drop rules on connect.
+	Bootstrap *Bootstrap
+	// Scopes route addresses from matching scenarios into port scoped
+	// groups instead of the main group, unless another decision blocks
+	// the address outright.
+	Scopes []Scope
+	// Services are scopes built from the router's port forwards.
+	Services []Service
+
+	// AllowDHCP and AllowLAN skip decisions for the router's DHCP clients
+	// and the subnets of LANInterfaces.
+	AllowDHCP     bool
+	AllowLAN      bool
+	LANInterfaces []string
+
+	// MaxEntries, when positive, caps the number of entries pushed to
+	// each group. Policy decides which entries are kept; the rest are
+	// pushed once there is room again.
+	MaxEntries int
+	Policy     decisions.Policy
+
+	// BatchSize is the number of values sent per request. With a
+	// BatchTarget it is adapted, up to BatchMax, to keep each commit
+	// under the target.
+	BatchSize   int
+	BatchMax    int
+	BatchTarget time.Duration
+	// ReplaceThreshold, when set, replaces a group's members in a single
+	// batch once more than this many values change, rather than sending
+	// the changes in batches.
+	ReplaceThreshold int
+	// Audit, when set, records every value added to or removed from the
+	// router.
+	Audit *audit.Log
+	// SwapGroup, when set, is a standby for Group. Changes to the main
+	// group beyond ReplaceThreshold fill the standby and repoint the
+	// firewall rules at it instead of replacing the group in place.
+	SwapGroup string
+
+	client *xedgeos.Client
+	ag     *xedgeos.AddressGroupCollection
+	ng     *xedgeos.NetworkGroupCollection
+	v6     *xedgeos.NetworkGroupCollection
+	owner  *decisions.Ownership
+
+	serviceScopes  []Scope
+	staleServices  []string
+	servicesLoaded time.Time
+	allow          allowlist
+	allowLoaded    time.Time
+	batch          *batchSize
+	swapped        bool
+	// readOnly is set when the groups were loaded by inspect, so the next
+	// push connects properly first.
+	readOnly bool
+	// entries are the decisions behind the values on the router, so
+	// unbans can be audited with the decision that caused the ban.
+	entries map[string]decisions.Entry
+	log     *slog.Logger
+	updates chan []decisions.Entry
+}
+
+// NewRouter returns a Router for cfg. owned lists the entries the bouncer is
+// known to have added to the groups previously.
+func NewRouter(cfg config.RouterConfig, keepUnmanaged bool, owned []decisions.Entry) (*Router, error) {
+	client, err := xedgeos.NewClient(cfg.Url, cfg.User, cfg.Pass)
+	if err != nil {
+		return nil, err
+	}
+
+	tc, err := tlsConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("router %s: %w", cfg.Name, err)
+	}
+	client.SetTLSConfig(tc)
+	log := slog.Default().With("router", cfg.Name)
+	client.SetLogger(log)
+
+	values := make([]string, len(owned))
+	entries := make(map[string]decisions.Entry, len(owned))
+	for i, e := range owned {
+		values[i], entries[e.Value] = e.Value, e
+	}
+
+	return &Router{
+		Name:             cfg.Name,
+		Group:            cfg.Group,
+		NetworkGroup:     cfg.NetworkGroup,
+		IPv6NetworkGroup: cfg.IPv6NetworkGroup,
+		KeepUnmanaged:    keepUnmanaged,
+		client:           client,
+		owner:            decisions.NewOwnership(values),
+		entries:          entries,
+		log:              log,
+		updates:          make(chan []decisions.Entry, 1),
+	}, nil
+}
+
+// Handles reports whether the router has a group configured for values of
+// the given kind.
+func (r *Router) Handles(k Kind) bool {
+	switch k {
+	case KindAddress:
+		return r.Group != ""
+	case KindNetwork:
+		return r.NetworkGroup != ""
+	case KindIPv6Network:
+		return r.IPv6NetworkGroup != ""
+	default:
+		return false
+	}
+}
+
+func tlsConfig(cfg config.RouterConfig) (*tls.Config, error) {
+	tc := &tls.Config{}
+	if cfg.InsecureSkipVerify != nil {
+		tc.InsecureSkipVerify = *cfg.InsecureSkipVerify
+	}
+	if cfg.CAFile == "" {
+		return tc, nil
+	}
+
+	pem, err := os.ReadFile(cfg.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	tc.RootCAs = x509.NewCertPool()
+	if !tc.RootCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", cfg.CAFile)
+	}
+
+	return tc, nil
+}
+
+// Update queues want to be pushed to the router, replacing any earlier update
+// that has not been applied yet. It must only be called from one goroutine.
+func (r *Router) Update(want []decisions.Entry) {
+	select {
+	case <-r.updates:
+	default:
+	}
+	r.updates <- want
+}
+
+// Run connects to the router and applies updates until ctx is cancelled. The
+// router's name is sent on synced after every successful push.
+func (r *Router) Run(ctx context.Context, synced chan<- string) {
+	var (
+		want     []decisions.Entry
+		received bool
+		pending  bool
+		failures int
+		retry    = time.NewTimer(0)
+		reload   <-chan time.Time
+	)
+	defer retry.Stop()
+	if len(r.Services) > 0 {
+		t := time.NewTicker(serviceTTL)
+		defer t.Stop()
+		reload = t.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case want = <-r.updates:
+			received, pending = true, true
+			if failures > 0 {
+				// Still backing off, the retry timer will pick this up.
+				continue
+			}
+		case <-reload:
+			// Push again so values follow any change to the port
+			// forwards.
+			pending = pending || received
+			if failures > 0 {
+				continue
+			}
+		case <-retry.C:
+		}
+
+		if err := r.step(want, pending); err != nil {
+			failures++
+			delay := backoff(failures)
+			r.log.Error("sync failed", "err", err, "retry", delay)
+			routerUp.WithLabelValues(r.Name).Set(0)
+			retry.Reset(delay)
+
+			continue
+		}
+
+		failures = 0
+		routerUp.WithLabelValues(r.Name).Set(1)
+		if pending {
+			pending = false
+			select {
+			case synced <- r.Name:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func backoff(failures int) time.Duration {
+	d := minBackoff
+	for i := 1; i < failures && d < maxBackoff; i++ {
+		d *= 2
+	}
+
+	return min(d, maxBackoff)
+}
+
+func (r *Router) step(want []decisions.Entry, pending bool) error {
+	if r.ag == nil || r.readOnly {
+		if err := r.connect(); err != nil {
+			return err
+		}
+	} else if err := r.reloadServices(); err != nil {
+		r.ag = nil
+		return err
+	}
+	if !pending {
+		return nil
+	}
+
+	if err := r.push(want); err != nil {
+		routerSyncs.WithLabelValues(r.Name, "error").Inc()
+		// Force a fresh login and snapshot in case the session expired or
+		// the push was only partially applied.
+		r.ag = nil
+
+		return err
+	}
+	routerSyncs.WithLabelValues(r.Name, "success").Inc()
+
+	return nil
+}
+
+// connect logs in, creates whatever groups and rules are missing and loads
+// the groups.
+func (r *Router) connect() error {
+	if err := r.login(); err != nil {
+		return err
+	}
+	if r.Bootstrap != nil {
+		if err := r.bootstrap(); err != nil {
+			return err
+		}
+	}
+	if r.Group != "" && r.SwapGroup != "" {
+		if err := r.ensureSwapGroup(); err != nil {
+			return err
+		}
+	}
+	if err := r.resolve(); err != nil {
+		return err
+	}
+	if len(r.Services) > 0 {
+		if err := r.clearServices(r.staleServices); err != nil {
+			return err
+		}
+	}
+	if len(r.allScopes()) > 0 {
+		if err := r.ensureScopes(); err != nil {
+			return err
+		}
+	}
+	if err := r.refresh(); err != nil {
+		return err
+	}
+	r.readOnly = false
+
+	if r.KeepUnmanaged {
+		if group := r.activeGroup(); group != "" {
+			r.log.Info("unmanaged entries", "group", group, "entries", len(r.owner.Unmanaged((*r.ag)[group].Address)))
+		}
+		if r.NetworkGroup != "" {
+			r.log.Info("unmanaged entries", "group", r.NetworkGroup, "entries", len(r.owner.Unmanaged((*r.ng)[r.NetworkGroup].Network)))
+		}
+		if r.IPv6NetworkGroup != "" {
+			r.log.Info("unmanaged entries", "group", r.IPv6NetworkGroup, "entries", len(r.owner.Unmanaged((*r.v6)[r.IPv6NetworkGroup].Network)))
+		}
+	}
+
+	return nil
+}
+
+// inspect logs in and loads the groups without changing anything on the
+// router. Groups that have not been created yet are an error.
+func (r *Router) inspect() error {
+	if err := r.login(); err != nil {
+		return err
+	}
+	if err := r.resolve(); err != nil {
+		return err
+	}
+	if err := r.refresh(); err != nil {
+		return err
+	}
+	r.readOnly = true
+
+	return nil
+}
+
+func (r *Router) login() error {
+	if err := r.client.Login(); err != nil {
+		return err
+	}
+	if info, err := r.client.SystemInfo(); err != nil {
+		r.log.Warn("unable to read system info", "err", err)
+	} else {
+		r.log.Info("connected", "model", info.Model, "version", info.Version)
+		routerInfo.WithLabelValues(r.Name, info.Model, info.Version).Set(1)
+	}
+	if r.AllowDHCP || r.AllowLAN {
+		if err := r.loadAllowlist(); err != nil {
+			r.log.Warn("unable to load allowlist", "err", err)
+		}
+	}
+
+	return nil
+}
+
+// resolve works out which address group is live and builds the services'
+// scopes, reading from the router only.
+func (r *Router) resolve() error {
+	if r.Group != "" && r.SwapGroup != "" {
+		if err := r.resolveSwap(); err != nil {
+			return err
+		}
+	}
+	if len(r.Services) > 0 {
+		if err := r.resolveServices(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Router) refresh() error {
+	res, err := r.client.GetPath("firewall", "group")
+	if err != nil {
+		return err
+	}
+	ag, err := xedgeos.NewAddressGroups(res)
+	if err != nil {
+		return err
+	}
+	if r.Group != "" {
+		if _, err := ag.GetGroup(r.activeGroup()); err != nil {
+			return err
+		}
+	}
+	for _, sc := range r.allScopes() {
+		if _, err := ag.GetGroup(sc.Group); err != nil {
+			return err
+		}
+		routerGroupEntries.WithLabelValues(r.Name, sc.Group).Set(float64(len((*ag)[sc.Group].Address)))
+	}
+	ng, err := xedgeos.NewNetworkGroups(res)
+	if err != nil {
+		return err
+	}
+	if r.NetworkGroup != "" {
+		if _, err := ng.GetGroup(r.NetworkGroup); err != nil {
+			return err
+		}
+		routerGroupEntries.WithLabelValues(r.Name, r.NetworkGroup).Set(float64(len((*ng)[r.NetworkGroup].Network)))
+	}
+	v6, err := xedgeos.NewIPv6NetworkGroups(res)
+	if err != nil {
+		return err
+	}
+	if r.IPv6NetworkGroup != "" {
+		if _, err := v6.GetGroup(r.IPv6NetworkGroup); err != nil {
+			return err
+		}
+		routerGroupEntries.WithLabelValues(r.Name, r.IPv6NetworkGroup).Set(float64(len((*v6)[r.IPv6NetworkGroup].Network)))
+	}
+	r.ag, r.ng, r.v6 = ag, ng, v6
+	if group := r.activeGroup(); group != "" {
+		routerGroupEntries.WithLabelValues(r.Name, group).Set(float64(len((*ag)[group].Address)))
+	}
+
+	return nil
+}
+
+// groupChange is the pending update of a single group on the router. The
+// values to add and remove are turned into payloads by set and del, values
+// is everything the group will hold afterwards.
+type groupChange struct {
+	name        string
+	old, new    int
+	values      []string
+	add, remove []string
+	set, del    func([]string) map[string]any
+}
+
+func (r *Router) unmanaged(current []string) []string {
+	if !r.KeepUnmanaged {
+		return nil
+	}
+
+	return r.owner.Unmanaged(current)
+}
+
+func (r *Router) addressChange(name string, want []string) (groupChange, []string, error) {
+	group, err := r.ag.GetGroup(name)
+	if err != nil {
+		return groupChange{}, nil, err
+	}
+	c := groupChange{name: group.Name, old: len(group.Address)}
+
+	unmanaged := r.unmanaged(group.Address)
+	old := group.Address
+	group.Address = decisions.Merge(unmanaged, want)
+	c.new = len(group.Address)
+	c.values = group.Address
+	c.add, c.remove = xedgeos.Diff(old, group.Address)
+	c.set, c.del = group.SetData, group.DeleteData
+
+	return c, unmanaged, nil
+}
+
+func (r *Router) networkChange(coll *xedgeos.NetworkGroupCollection, name string, want []string) (groupChange, []string, error) {
+	group, err := coll.GetGroup(name)
+	if err != nil {
+		return groupChange{}, nil, err
+	}
+	c := groupChange{name: group.Name, old: len(group.Network)}
+
+	unmanaged := r.unmanaged(group.Network)
+	old := group.Network
+	group.Network = decisions.Merge(unmanaged, want)
+	c.new = len(group.Network)
+	c.values = group.Network
+	c.add, c.remove = xedgeos.Diff(old, group.Network)
+	c.set, c.del = group.SetData, group.DeleteData
+
+	return c, unmanaged, nil
+}
+
+// capped returns one entry for each value that fits in the group under
+// MaxEntries, along with their values.
+func (r *Router) capped(group string, entries []decisions.Entry) ([]decisions.Entry, []string) {
+	kept, evicted := r.Policy.Cap(entries, r.MaxEntries)
+	if len(evicted) > 0 {
+		r.log.Warn("group at capacity, entries evicted", "group", group, "entries", len(evicted))
+	}
+
+	values := make([]string, len(kept))
+	for i, e := range kept {
+		values[i] = e.Value
+	}
+
+	return kept, values
+}
+
+// plan works out the changes needed to bring every group in line with want.
+// It also returns the entries that will be on the router afterwards and the
+// values left alone as unmanaged.
+func (r *Router) plan(want []decisions.Entry) (changes []groupChange, pushed []decisions.Entry, unmanaged []string, err error) {
+	want = r.allowed(want)
+
+	// A value with any unscoped decision is blocked outright, which
+	// covers its scoped decisions too.
+	outright := map[string]bool{}
+	for _, e := range want {
+		if _, ok := r.scopeFor(e); !ok {
+			outright[e.Value] = true
+		}
+	}
+
+	var (
+		scoped = map[string][]decisions.Entry{}
+		kinds  = map[Kind][]decisions.Entry{}
+	)
+	for _, e := range want {
+		s, ok := r.scopeFor(e)
+		switch {
+		case !ok:
+			if k := KindOf(e.Value); k != KindUnsupported {
+				kinds[k] = append(kinds[k], e)
+			}
+		case !outright[e.Value]:
+			scoped[s.Group] = append(scoped[s.Group], e)
+		}
+	}
+
+	if r.Group != "" {
+		group := r.activeGroup()
+		kept, values := r.capped(group, kinds[KindAddress])
+		c, u, err := r.addressChange(group, values)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		changes, unmanaged = append(changes, c), append(unmanaged, u...)
+		pushed = append(pushed, kept...)
+	}
+
+	for _, s := range r.allScopes() {
+		kept, values := r.capped(s.Group, scoped[s.Group])
+		c, u, err := r.addressChange(s.Group, values)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		changes, unmanaged = append(changes, c), append(unmanaged, u...)
+		pushed = append(pushed, kept...)
+	}
+
+	for _, g := range []struct {
+		coll *xedgeos.NetworkGroupCollection
+		name string
+		kind Kind
+	}{
+		{r.ng, r.NetworkGroup, KindNetwork},
+		{r.v6, r.IPv6NetworkGroup, KindIPv6Network},
+	} {
+		if g.name == "" {
+			continue
+		}
+		kept, values := r.capped(g.name, kinds[g.kind])
+		c, u, err := r.networkChange(g.coll, g.name, values)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		changes, unmanaged = append(changes, c), append(unmanaged, u...)
+		pushed = append(pushed, kept...)
+	}
+
+	return changes, pushed, unmanaged, nil
+}
+
+// replace deletes the group's members and sets the new ones in a single
+// batch, so the router commits once however large the change is.
+func (r *Router) replace(c groupChange) error {
+	var data xedgeos.BatchData
+	if c.old > 0 {
+		data.Delete = c.del(nil)
+	}
+	if len(c.values) > 0 {
+		data.Set = c.set(c.values)
+	}
+	_, err := r.client.Batch(data)
+
+	return err
+}
+
+func (r *Router) push(want []decisions.Entry) error {
+	r.log.Debug("updating groups")
+
+	changes, pushed, unmanaged, err := r.plan(want)
+	if err != nil {
+		return err
+	}
+	entries := make(map[string]decisions.Entry, len(pushed))
+	for _, e := range pushed {
+		entries[e.Value] = e
+	}
+
+	for _, c := range changes {
+		r.log.Info("updating group", "group", c.name, "old", c.old, "new", c.new)
+		if r.ReplaceThreshold > 0 && len(c.add)+len(c.remove) > r.ReplaceThreshold {
+			var (
+				group = c.name
+				err   error
+			)
+			if r.SwapGroup != "" && c.name == r.activeGroup() {
+				r.log.Info("swapping group", "group", c.name, "standby", r.standbyGroup())
+				group, err = r.swap(c)
+			} else {
+				r.log.Info("replacing whole group", "group", c.name)
+				err = r.replace(c)
+			}
+			r.record(audit.Unban, c.name, c.remove, r.entries, err)
+			r.record(audit.Ban, group, c.add, entries, err)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		unban := func(values []string, err error) { r.record(audit.Unban, c.name, values, r.entries, err) }
+		if err := r.send(c.remove, c.del, r.client.Delete, unban); err != nil {
+			return err
+		}
+		ban := func(values []string, err error) { r.record(audit.Ban, c.name, values, entries, err) }
+		if err := r.send(c.add, c.set, r.client.Set, ban); err != nil {
+			return err
+		}
+	}
+
+	r.log.Debug("groups updated")
+	r.owner.Commit(slices.Collect(maps.Keys(entries)), unmanaged)
+	// Pick up new decisions for values that were already on the router.
+	maps.Copy(r.entries, entries)
+
+	if err := r.refresh(); err != nil {
+		return err
+	}
+	if group := r.activeGroup(); group != "" {
+		r.log.Debug("stored address count", "group", group, "entries", len((*r.ag)[group].Address))
+	}
+
+	return nil
+}
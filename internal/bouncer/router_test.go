@@ -0,0 +1,493 @@
+package bouncer
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jacobalberty/cs-edgeos-bouncer/internal/audit"
+	"github.com/jacobalberty/cs-edgeos-bouncer/internal/config"
+	"github.com/jacobalberty/cs-edgeos-bouncer/internal/decisions"
+	"github.com/jacobalberty/cs-edgeos-bouncer/pkg/xedgeos"
+	"github.com/jacobalberty/cs-edgeos-bouncer/pkg/xedgeos/xedgeostest"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRouter(t *testing.T, srv *xedgeostest.Server) *Router {
+	r, err := NewRouter(config.RouterConfig{
+		Name:         "test",
+		User:         "ubnt",
+		Pass:         "ubnt",
+		Url:          srv.URL,
+		Group:        "CROWDSEC",
+		NetworkGroup: "CROWDSEC_NET",
+	}, false, nil)
+	assert.NoError(t, err)
+
+	return r
+}
+
+func entries(values ...string) []decisions.Entry {
+	out := make([]decisions.Entry, len(values))
+	for i, v := range values {
+		out[i] = decisions.Entry{Value: v}
+	}
+
+	return out
+}
+
+func TestRouterBootstrapPush(t *testing.T) {
+	asrt := assert.New(t)
+	srv := xedgeostest.NewServer(map[string]any{
+		"firewall": map[string]any{
+			"name": map[string]any{"WAN_IN": map[string]any{"default-action": "drop"}},
+		},
+	})
+	defer srv.Close()
+
+	r := newTestRouter(t, srv)
+	r.Bootstrap = &Bootstrap{Description: "CrowdSec bouncer", Rulesets: []string{"WAN_IN"}, Rule: 1}
+
+	asrt.NoError(r.step(entries("198.51.100.1", "203.0.113.0/24", "2001:db8::1"), true))
+	addrs, _ := srv.Lookup("firewall", "group", "address-group", "CROWDSEC", "address")
+	asrt.Equal([]string{"198.51.100.1"}, addrs.Values())
+	nets, _ := srv.Lookup("firewall", "group", "network-group", "CROWDSEC_NET", "network")
+	asrt.Equal([]string{"203.0.113.0/24"}, nets.Values())
+	action, _ := srv.Lookup("firewall", "name", "WAN_IN", "rule", "2", "action")
+	v, _ := action.Value()
+	asrt.Equal("drop", v)
+
+	asrt.NoError(r.step(entries("198.51.100.2"), true))
+	addrs, _ = srv.Lookup("firewall", "group", "address-group", "CROWDSEC", "address")
+	asrt.Equal([]string{"198.51.100.2"}, addrs.Values())
+	_, ok := srv.Lookup("firewall", "group", "network-group", "CROWDSEC_NET", "network")
+	asrt.False(ok)
+}
+
+func TestRouterReconnect(t *testing.T) {
+	asrt := assert.New(t)
+	srv := xedgeostest.NewServer(map[string]any{
+		"firewall": map[string]any{
+			"group": map[string]any{
+				"address-group": map[string]any{"CROWDSEC": map[string]any{}},
+				"network-group": map[string]any{"CROWDSEC_NET": map[string]any{}},
+			},
+		},
+	})
+	defer srv.Close()
+
+	r := newTestRouter(t, srv)
+	asrt.NoError(r.step(entries("198.51.100.1"), true))
+
+	// An expired session fails the push and forces a fresh login.
+	srv.ExpireSessions()
+	asrt.Error(r.step(entries("198.51.100.2"), true))
+	asrt.Nil(r.ag)
+	asrt.NoError(r.step(entries("198.51.100.2"), true))
+	asrt.Equal(2, srv.Requests(xedgeostest.EndpointLogin))
+
+	srv.FailNext(xedgeostest.EndpointSet, 1)
+	asrt.Error(r.step(entries("198.51.100.3"), true))
+	asrt.NoError(r.step(entries("198.51.100.3"), true))
+
+	addrs, _ := srv.Lookup("firewall", "group", "address-group", "CROWDSEC", "address")
+	asrt.Equal([]string{"198.51.100.3"}, addrs.Values())
+}
+
+func TestRouterReplace(t *testing.T) {
+	asrt := assert.New(t)
+	srv := xedgeostest.NewServer(map[string]any{
+		"firewall": map[string]any{
+			"group": map[string]any{
+				"address-group": map[string]any{
+					"CROWDSEC": map[string]any{"address": []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}},
+				},
+				"network-group": map[string]any{"CROWDSEC_NET": map[string]any{}},
+			},
+		},
+	})
+	defer srv.Close()
+
+	r := newTestRouter(t, srv)
+	r.BatchSize = 2
+	r.ReplaceThreshold = 4
+
+	// Small changes are still sent in batches.
+	asrt.NoError(r.Sync(entries("192.0.2.1", "192.0.2.2", "192.0.2.4")))
+	asrt.Equal(0, srv.Requests(xedgeostest.EndpointBatch))
+	asrt.Equal(1, srv.Requests(xedgeostest.EndpointSet))
+
+	asrt.NoError(r.Sync(entries("192.0.2.1", "198.51.100.1", "198.51.100.2", "198.51.100.3")))
+	asrt.Equal(1, srv.Requests(xedgeostest.EndpointBatch))
+	asrt.Equal(1, srv.Requests(xedgeostest.EndpointSet))
+	eventually(t, srv, addressPath, "192.0.2.1", "198.51.100.1", "198.51.100.2", "198.51.100.3")
+
+	r.ReplaceThreshold = 1
+	asrt.NoError(r.Sync(nil))
+	asrt.Equal(2, srv.Requests(xedgeostest.EndpointBatch))
+	_, ok := srv.Lookup("firewall", "group", "address-group", "CROWDSEC", "address")
+	asrt.False(ok)
+	_, ok = srv.Lookup("firewall", "group", "address-group", "CROWDSEC")
+	asrt.True(ok)
+}
+
+func TestRouterSwap(t *testing.T) {
+	asrt := assert.New(t)
+	srv := xedgeostest.NewServer(map[string]any{
+		"firewall": map[string]any{
+			"group": map[string]any{
+				"address-group": map[string]any{
+					"CROWDSEC": map[string]any{"address": []string{"192.0.2.1", "192.0.2.2"}},
+				},
+				"network-group": map[string]any{"CROWDSEC_NET": map[string]any{}},
+			},
+			"name": map[string]any{
+				"WAN_IN": map[string]any{
+					"rule": map[string]any{
+						"1": map[string]any{
+							"action": "drop",
+							"source": map[string]any{"group": map[string]any{"address-group": "CROWDSEC"}},
+						},
+					},
+				},
+			},
+		},
+	})
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	r := newTestRouter(t, srv)
+	r.ReplaceThreshold = 3
+	r.SwapGroup = "CROWDSEC_B"
+	r.Audit = audit.New(&buf)
+
+	rule := func() string {
+		v, _ := srv.Lookup("firewall", "name", "WAN_IN", "rule", "1", "source", "group", "address-group")
+		s, _ := v.Value()
+		return s
+	}
+	members := func(group string) []string {
+		v, _ := srv.Lookup("firewall", "group", "address-group", group, "address")
+		return v.Values()
+	}
+
+	// Small changes update the live group in place.
+	asrt.NoError(r.Sync(entries("192.0.2.1", "192.0.2.3")))
+	asrt.Equal("CROWDSEC", rule())
+	asrt.Equal([]string{"192.0.2.1", "192.0.2.3"}, members("CROWDSEC"))
+
+	asrt.NoError(r.Sync(entries("198.51.100.1", "198.51.100.2", "198.51.100.3")))
+	asrt.Equal("CROWDSEC_B", rule())
+	asrt.Equal([]string{"198.51.100.1", "198.51.100.2", "198.51.100.3"}, members("CROWDSEC_B"))
+	asrt.Empty(members("CROWDSEC"))
+	// Bans land in the standby group, unbans leave the old one.
+	groups := map[audit.Action]map[string]bool{audit.Ban: {}, audit.Unban: {}}
+	for _, rec := range auditRecords(t, &buf) {
+		groups[rec.Action][rec.Group] = true
+	}
+	asrt.Equal(map[string]bool{"CROWDSEC": true, "CROWDSEC_B": true}, groups[audit.Ban])
+	asrt.Equal(map[string]bool{"CROWDSEC": true}, groups[audit.Unban])
+
+	asrt.NoError(r.Sync(entries("203.0.113.1", "203.0.113.2")))
+	asrt.Equal("CROWDSEC", rule())
+	asrt.Equal([]string{"203.0.113.1", "203.0.113.2"}, members("CROWDSEC"))
+	asrt.Empty(members("CROWDSEC_B"))
+
+	// A fresh router picks up which group is live from the rules.
+	r = newTestRouter(t, srv)
+	r.SwapGroup = "CROWDSEC_B"
+	asrt.NoError(r.Sync(entries("203.0.113.1")))
+	asrt.False(r.swapped)
+	asrt.Equal([]string{"203.0.113.1"}, members("CROWDSEC"))
+}
+
+// auditRecords decodes the records written to buf since the last call.
+func auditRecords(t *testing.T, buf *bytes.Buffer) []audit.Record {
+	var out []audit.Record
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var rec audit.Record
+		assert.NoError(t, dec.Decode(&rec))
+		out = append(out, rec)
+	}
+
+	return out
+}
+
+func TestRouterAudit(t *testing.T) {
+	asrt := assert.New(t)
+	srv := xedgeostest.NewServer(map[string]any{
+		"firewall": map[string]any{
+			"group": map[string]any{
+				"address-group": map[string]any{"CROWDSEC": map[string]any{}},
+				"network-group": map[string]any{"CROWDSEC_NET": map[string]any{}},
+			},
+		},
+	})
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	r := newTestRouter(t, srv)
+	r.Audit = audit.New(&buf)
+	records := func() []audit.Record { return auditRecords(t, &buf) }
+
+	ban := decisions.Entry{Value: "192.0.2.1", ID: 7, Origin: "crowdsec", Scenario: "crowdsecurity/ssh-bf"}
+	asrt.NoError(r.Sync([]decisions.Entry{ban}))
+	recs := records()
+	if asrt.Len(recs, 1) {
+		asrt.Equal(audit.Ban, recs[0].Action)
+		asrt.Equal("192.0.2.1", recs[0].Value)
+		asrt.Equal("test", recs[0].Router)
+		asrt.Equal("CROWDSEC", recs[0].Group)
+		asrt.Equal(int64(7), recs[0].ID)
+		asrt.Equal("crowdsecurity/ssh-bf", recs[0].Scenario)
+		asrt.Equal(audit.ResultOK, recs[0].Result)
+	}
+
+	// A rejected push is recorded along with the error.
+	srv.FailNext(xedgeostest.EndpointSet, 1)
+	asrt.Error(r.Sync(entries("192.0.2.1", "192.0.2.2")))
+	recs = records()
+	if asrt.Len(recs, 1) {
+		asrt.Equal("192.0.2.2", recs[0].Value)
+		asrt.Equal(audit.ResultError, recs[0].Result)
+		asrt.NotEmpty(recs[0].Error)
+	}
+
+	// So is one the router answers but reports as failed, and the address
+	// is not taken as the bouncer's.
+	srv.RejectNext(xedgeostest.EndpointSet, 1)
+	err := r.Sync(entries("192.0.2.1", "192.0.2.2"))
+	var changeErr *xedgeos.ChangeError
+	asrt.ErrorAs(err, &changeErr)
+	recs = records()
+	if asrt.Len(recs, 1) {
+		asrt.Equal("192.0.2.2", recs[0].Value)
+		asrt.Equal(audit.ResultError, recs[0].Result)
+		asrt.Contains(recs[0].Error, "injected rejection")
+	}
+	asrt.False(r.owner.Owned("192.0.2.2"))
+	eventually(t, srv, addressPath, "192.0.2.1")
+
+	// Unbans carry the decision that put the address there.
+	asrt.NoError(r.Sync(nil))
+	recs = records()
+	if asrt.Len(recs, 1) {
+		asrt.Equal(audit.Unban, recs[0].Action)
+		asrt.Equal("192.0.2.1", recs[0].Value)
+		asrt.Equal(int64(7), recs[0].ID)
+		asrt.Equal(audit.ResultOK, recs[0].Result)
+	}
+
+	// Groups confirmed before a failure keep their decisions.
+	network := decisions.Entry{Value: "198.51.100.0/24", ID: 8}
+	asrt.NoError(r.Sync([]decisions.Entry{network}))
+	records()
+	srv.FailNext(xedgeostest.EndpointDelete, 1)
+	asrt.Error(r.Sync([]decisions.Entry{ban, {Value: "203.0.113.0/24"}}))
+	records()
+	asrt.NoError(r.Sync([]decisions.Entry{network}))
+	recs = records()
+	if asrt.Len(recs, 1) {
+		asrt.Equal(audit.Unban, recs[0].Action)
+		asrt.Equal("192.0.2.1", recs[0].Value)
+		asrt.Equal(int64(7), recs[0].ID)
+	}
+
+	// Manual changes are recorded too.
+	asrt.NoError(r.Apply([]string{"203.0.113.1"}, []string{"198.51.100.0/24"}))
+	recs = records()
+	if asrt.Len(recs, 2) {
+		asrt.Equal(audit.Ban, recs[0].Action)
+		asrt.Equal("203.0.113.1", recs[0].Value)
+		asrt.Equal("manual", recs[0].Origin)
+		asrt.Equal(audit.Unban, recs[1].Action)
+		asrt.Equal(int64(8), recs[1].ID)
+	}
+}
+
+func TestRouterAuditResumed(t *testing.T) {
+	asrt := assert.New(t)
+	srv := xedgeostest.NewServer(map[string]any{
+		"firewall": map[string]any{
+			"group": map[string]any{
+				"address-group": map[string]any{
+					"CROWDSEC": map[string]any{"address": []string{"192.0.2.1"}},
+				},
+				"network-group": map[string]any{"CROWDSEC_NET": map[string]any{}},
+			},
+		},
+	})
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	r, err := NewRouter(config.RouterConfig{
+		Name:         "test",
+		User:         "ubnt",
+		Pass:         "ubnt",
+		Url:          srv.URL,
+		Group:        "CROWDSEC",
+		NetworkGroup: "CROWDSEC_NET",
+	}, true, []decisions.Entry{{Value: "192.0.2.1", ID: 7, Scenario: "crowdsecurity/ssh-bf"}})
+	asrt.NoError(err)
+	r.Audit = audit.New(&buf)
+
+	// An unban after a restart still knows which decision it ends.
+	asrt.NoError(r.Sync(nil))
+	recs := auditRecords(t, &buf)
+	if asrt.Len(recs, 1) {
+		asrt.Equal(audit.Unban, recs[0].Action)
+		asrt.Equal(int64(7), recs[0].ID)
+		asrt.Equal("crowdsecurity/ssh-bf", recs[0].Scenario)
+	}
+}
+
+func TestRouterNetworkOnly(t *testing.T) {
+	asrt := assert.New(t)
+	srv := xedgeostest.NewServer(map[string]any{
+		"firewall": map[string]any{
+			"name": map[string]any{"WAN_IN": map[string]any{"default-action": "drop"}},
+		},
+	})
+	defer srv.Close()
+
+	r := newTestRouter(t, srv)
+	r.Group = ""
+	r.Bootstrap = &Bootstrap{Description: "CrowdSec bouncer", Rulesets: []string{"WAN_IN"}, Rule: 1}
+
+	asrt.NoError(r.Sync(entries("203.0.113.0/24")))
+	eventually(t, srv, networkPath, "203.0.113.0/24")
+	_, ok := srv.Lookup("firewall", "group", "address-group")
+	asrt.False(ok)
+	_, ok = srv.Lookup("firewall", "name", "WAN_IN", "rule", "1")
+	asrt.False(ok)
+	_, ok = srv.Lookup("firewall", "name", "WAN_IN", "rule", "2")
+	asrt.True(ok)
+
+	st, err := r.Status()
+	asrt.NoError(err)
+	asrt.Equal([]GroupStatus{{"CROWDSEC_NET", 1}}, st.Groups)
+}
+
+func TestRouterSwapWithoutRules(t *testing.T) {
+	asrt := assert.New(t)
+	srv := xedgeostest.NewServer(map[string]any{
+		"firewall": map[string]any{
+			"group": map[string]any{
+				"address-group": map[string]any{
+					"CROWDSEC": map[string]any{"address": []string{"192.0.2.1"}},
+				},
+				"network-group": map[string]any{"CROWDSEC_NET": map[string]any{}},
+			},
+		},
+	})
+	defer srv.Close()
+
+	r := newTestRouter(t, srv)
+	r.ReplaceThreshold = 1
+	r.SwapGroup = "CROWDSEC_B"
+
+	// With no rule to repoint the group is replaced in place.
+	asrt.NoError(r.Sync(entries("198.51.100.1", "198.51.100.2")))
+	eventually(t, srv, addressPath, "198.51.100.1", "198.51.100.2")
+	asrt.False(r.swapped)
+	b, _ := srv.Lookup("firewall", "group", "address-group", "CROWDSEC_B", "address")
+	asrt.Empty(b.Values())
+}
+
+func TestRouterMaxEntries(t *testing.T) {
+	asrt := assert.New(t)
+	srv := xedgeostest.NewServer(map[string]any{
+		"firewall": map[string]any{
+			"group": map[string]any{
+				"address-group": map[string]any{"CROWDSEC": map[string]any{}},
+				"network-group": map[string]any{"CROWDSEC_NET": map[string]any{}},
+			},
+		},
+	})
+	defer srv.Close()
+	srv.SetData("dhcp_leases", map[string]any{
+		"dhcp-server-leases": map[string]any{
+			"LAN": map[string]any{"10.10.0.5": map[string]any{"pool": "LAN"}},
+		},
+	})
+
+	r := newTestRouter(t, srv)
+	r.AllowDHCP = true
+	r.MaxEntries, r.Policy = 1, decisions.Policy{decisions.ByRecent}
+
+	now := time.Now()
+	want := []decisions.Entry{
+		{Value: "10.10.0.5", Added: now},
+		{Value: "198.51.100.1", Added: now.Add(-time.Minute)},
+		{Value: "198.51.100.2", Added: now.Add(-time.Hour)},
+		{Value: "203.0.113.0/24", Added: now.Add(-time.Hour)},
+	}
+
+	// The cap applies to each group after the allowlist, so the DHCP
+	// client doesn't take the address group's only slot.
+	asrt.NoError(r.Sync(want))
+	eventually(t, srv, addressPath, "198.51.100.1")
+	eventually(t, srv, networkPath, "203.0.113.0/24")
+
+	// Evicted entries are pushed once there is room.
+	asrt.NoError(r.Sync(slices.Delete(want, 1, 2)))
+	eventually(t, srv, addressPath, "198.51.100.2")
+}
+
+func TestRouterResumeExpired(t *testing.T) {
+	asrt := assert.New(t)
+	srv := xedgeostest.NewServer(map[string]any{
+		"firewall": map[string]any{
+			"group": map[string]any{
+				"address-group": map[string]any{
+					"CROWDSEC": map[string]any{"address": []string{"192.0.2.1", "192.0.2.2", "192.0.2.9"}},
+				},
+				"network-group": map[string]any{
+					"CROWDSEC_NET": map[string]any{"network": []string{"203.0.113.0/24"}},
+				},
+			},
+		},
+	})
+	defer srv.Close()
+
+	now := time.Now()
+	path := t.TempDir() + "/state.json"
+	saved := decisions.NewSet()
+	saved.Add(decisions.Entry{Value: "192.0.2.1", ID: 1, Until: now.Add(-time.Hour)})
+	saved.Add(decisions.Entry{Value: "192.0.2.2", ID: 2, Until: now.Add(time.Hour)})
+	saved.Add(decisions.Entry{Value: "203.0.113.0/24", ID: 3, Until: now.Add(time.Hour)})
+	asrt.NoError(saved.Save(path))
+
+	// Resume the way the bouncer does, owning everything in the state
+	// file before expiring it.
+	desired := decisions.NewSet()
+	asrt.NoError(desired.Load(path))
+	owned := desired.Entries()
+	desired.Expire(now)
+
+	r, err := NewRouter(config.RouterConfig{
+		Name:         "test",
+		User:         "ubnt",
+		Pass:         "ubnt",
+		Url:          srv.URL,
+		Group:        "CROWDSEC",
+		NetworkGroup: "CROWDSEC_NET",
+	}, true, owned)
+	asrt.NoError(err)
+
+	// The ban that expired while the bouncer was down is removed, the
+	// hand-added entry stays.
+	asrt.NoError(r.Sync(desired.Entries()))
+	eventually(t, srv, addressPath, "192.0.2.2", "192.0.2.9")
+
+	// Values confirmed before a push fails are owned all the same.
+	srv.FailNext(xedgeostest.EndpointDelete, 1)
+	asrt.Error(r.Sync(entries("192.0.2.2", "192.0.2.3")))
+	eventually(t, srv, addressPath, "192.0.2.2", "192.0.2.3", "192.0.2.9")
+	asrt.NoError(r.Sync(nil))
+	eventually(t, srv, addressPath, "192.0.2.9")
+	eventually(t, srv, networkPath)
+}
@@ -0,0 +1,215 @@
+package bouncer
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jacobalberty/cs-edgeos-bouncer/internal/decisions"
+	"github.com/jacobalberty/cs-edgeos-bouncer/pkg/xedgeos"
+)
+
+// Scope blocks addresses from a set of scenarios on specific ports only,
+// rather than dropping all of their traffic. Matching addresses go into their
+// own address group which is paired with a port group in a drop rule.
+type Scope struct {
+	Name string
+	// Scenarios match any decision whose scenario contains one of them.
+	Scenarios []string
+	Group     string
+	PortGroup string
+	Ports     []string
+	Protocol  string
+	Rulesets  []string
+	Rule      int
+
+	// Address and Port match the destination directly instead of through
+	// a port group. They are used for scopes built from port forwards.
+	Address string
+	Port    string
+
+	// service marks scopes built from port forwards, whose rules follow
+	// the forward when it changes.
+	service bool
+}
+
+// Matches reports whether decisions for the scenario belong in the scope.
+func (s Scope) Matches(scenario string) bool {
+	for _, sc := range s.Scenarios {
+		if strings.Contains(scenario, sc) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s Scope) rule() xedgeos.Rule {
+	return xedgeos.Rule{
+		Action:      "drop",
+		Description: s.Name,
+		Protocol:    s.Protocol,
+		Source: &xedgeos.RuleTarget{
+			Group: &xedgeos.RuleGroup{AddressGroup: s.Group},
+		},
+		Destination: s.destination(),
+	}
+}
+
+func (s Scope) destination() *xedgeos.RuleTarget {
+	if s.PortGroup == "" {
+		return &xedgeos.RuleTarget{Address: s.Address, Port: s.Port}
+	}
+
+	return &xedgeos.RuleTarget{
+		Address: s.Address,
+		Group:   &xedgeos.RuleGroup{PortGroup: s.PortGroup},
+	}
+}
+
+func (s Scope) matchesRule(r xedgeos.Rule) bool {
+	want := s.rule()
+
+	if !r.DropsSource(*want.Source.Group) ||
+		r.Protocol != want.Protocol ||
+		r.Destination == nil ||
+		r.Destination.Address != want.Destination.Address ||
+		r.Destination.Port != want.Destination.Port {
+		return false
+	}
+	if want.Destination.Group == nil {
+		return r.Destination.Group == nil
+	}
+
+	return r.Destination.Group != nil && *r.Destination.Group == *want.Destination.Group
+}
+
+// allScopes returns the configured scopes followed by those built from the
+// router's port forwards.
+func (r *Router) allScopes() []Scope {
+	return slices.Concat(r.Scopes, r.serviceScopes)
+}
+
+// scopeFor returns the scope the entry belongs in, if any. Only single
+// addresses are scoped, prefixes are always blocked outright.
+func (r *Router) scopeFor(e decisions.Entry) (Scope, bool) {
+	if KindOf(e.Value) != KindAddress {
+		return Scope{}, false
+	}
+	for _, s := range r.allScopes() {
+		if s.Matches(e.Scenario) {
+			return s, true
+		}
+	}
+
+	return Scope{}, false
+}
+
+// ensureScopes creates the address groups, port groups and drop rules for
+// every scope. Existing port groups gain any missing ports, and a service's
+// own rule is rewritten when its forward has changed; any other rule at a
+// scope's rule number is an error rather than something to overwrite.
+func (r *Router) ensureScopes() error {
+	res, err := r.client.GetPath("firewall")
+	if err != nil {
+		return err
+	}
+	ag, err := xedgeos.NewAddressGroups(res)
+	if err != nil {
+		return err
+	}
+	pg, err := xedgeos.NewPortGroups(res)
+	if err != nil {
+		return err
+	}
+	rulesets, err := xedgeos.NewRulesets(res)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range r.allScopes() {
+		if _, err := ag.GetGroup(s.Group); err != nil {
+			r.log.Info("creating group", "group", s.Group)
+			if _, err := r.client.Set(ag.GetCreateData(&xedgeos.AddressGroup{
+				Name:        s.Group,
+				Description: s.Name,
+			})); err != nil {
+				return err
+			}
+		}
+
+		if s.PortGroup != "" {
+			if err := r.ensurePortGroup(pg, s); err != nil {
+				return err
+			}
+		}
+
+		for _, name := range s.Rulesets {
+			rs, err := rulesets.GetRuleset(name)
+			if err != nil {
+				return err
+			}
+			rule, ok := rs.Rules[s.Rule]
+			if ok && s.matchesRule(rule) {
+				continue
+			}
+			data, err := s.rule().GetSetData(name, s.Rule)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				r.log.Info("adding rule", "ruleset", name, "rule", s.Rule)
+				if _, err := r.client.Set(data); err != nil {
+					return err
+				}
+				continue
+			}
+			if !s.service || !rule.DropsSource(xedgeos.RuleGroup{AddressGroup: s.Group}) {
+				return fmt.Errorf("rule %d in %s is already in use", s.Rule, name)
+			}
+
+			r.log.Info("updating rule for changed port forward", "ruleset", name, "rule", s.Rule)
+			if _, err := r.client.Batch(xedgeos.BatchData{
+				Delete: xedgeos.GetRuleDeleteData(name, s.Rule),
+				Set:    data,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ensurePortGroup creates the scope's port group, or adds any of the scope's
+// ports it is missing.
+func (r *Router) ensurePortGroup(pg *xedgeos.PortGroupCollection, s Scope) error {
+	ports := slices.Sorted(slices.Values(s.Ports))
+
+	group, err := pg.GetGroup(s.PortGroup)
+	if err != nil {
+		r.log.Info("creating port group", "group", s.PortGroup)
+		_, err := r.client.Set(pg.GetCreateData(&xedgeos.PortGroup{
+			Name:        s.PortGroup,
+			Description: s.Name,
+			Port:        ports,
+		}))
+
+		return err
+	}
+
+	for _, p := range ports {
+		group.Add(p)
+	}
+	setData, err := pg.GetSetData(group)
+	if err != nil {
+		return err
+	}
+	for _, curSet := range setData {
+		if _, err := r.client.Set(curSet); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
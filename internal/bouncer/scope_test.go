@@ -0,0 +1,139 @@
+package bouncer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jacobalberty/cs-edgeos-bouncer/internal/decisions"
+	"github.com/jacobalberty/cs-edgeos-bouncer/pkg/xedgeos"
+	"github.com/jacobalberty/cs-edgeos-bouncer/pkg/xedgeos/xedgeostest"
+	"github.com/stretchr/testify/assert"
+)
+
+const sshPath = "firewall group address-group CROWDSEC_SSH address"
+
+var sshScope = Scope{
+	Name:      "ssh",
+	Scenarios: []string{"ssh-bf"},
+	Group:     "CROWDSEC_SSH",
+	PortGroup: "SSH_PORTS",
+	Ports:     []string{"22"},
+	Protocol:  "tcp",
+	Rulesets:  []string{"WAN_IN"},
+	Rule:      10,
+}
+
+// scopeServer returns a router with the main groups and a WAN_IN ruleset
+// holding rules.
+func scopeServer(rules map[string]any) *xedgeostest.Server {
+	return xedgeostest.NewServer(map[string]any{
+		"firewall": map[string]any{
+			"group": map[string]any{
+				"address-group": map[string]any{"CROWDSEC": map[string]any{}},
+				"network-group": map[string]any{"CROWDSEC_NET": map[string]any{}},
+			},
+			"name": map[string]any{
+				"WAN_IN": map[string]any{"default-action": "drop", "rule": rules},
+			},
+		},
+	})
+}
+
+func lookupValue(srv *xedgeostest.Server, path ...string) string {
+	n, _ := srv.Lookup(path...)
+	v, _ := n.Value()
+
+	return v
+}
+
+func TestScopeMatchesRule(t *testing.T) {
+	asrt := assert.New(t)
+
+	asrt.True(sshScope.matchesRule(sshScope.rule()))
+
+	rule := sshScope.rule()
+	rule.Protocol = "udp"
+	asrt.False(sshScope.matchesRule(rule))
+
+	rule = sshScope.rule()
+	rule.Destination = &xedgeos.RuleTarget{Port: "22"}
+	asrt.False(sshScope.matchesRule(rule))
+
+	rule = sshScope.rule()
+	rule.Source = &xedgeos.RuleTarget{Group: &xedgeos.RuleGroup{AddressGroup: "OTHER"}}
+	asrt.False(sshScope.matchesRule(rule))
+}
+
+func TestRouterScopeFor(t *testing.T) {
+	asrt := assert.New(t)
+	r := &Router{Scopes: []Scope{sshScope}}
+
+	s, ok := r.scopeFor(decisions.Entry{Value: "192.0.2.1", Scenario: "crowdsecurity/ssh-bf"})
+	asrt.True(ok)
+	asrt.Equal("CROWDSEC_SSH", s.Group)
+
+	_, ok = r.scopeFor(decisions.Entry{Value: "192.0.2.1", Scenario: "crowdsecurity/http-probing"})
+	asrt.False(ok)
+
+	// Prefixes are always blocked outright.
+	_, ok = r.scopeFor(decisions.Entry{Value: "192.0.2.0/24", Scenario: "crowdsecurity/ssh-bf"})
+	asrt.False(ok)
+}
+
+func TestRouterScopes(t *testing.T) {
+	asrt := assert.New(t)
+	srv := scopeServer(map[string]any{})
+	defer srv.Close()
+
+	r := newTestRouter(t, srv)
+	r.Scopes = []Scope{sshScope}
+
+	want := []decisions.Entry{
+		{Value: "192.0.2.1", Scenario: "crowdsecurity/ssh-bf"},
+		{Value: "192.0.2.2", Scenario: "crowdsecurity/http-probing"},
+		{Value: "198.51.100.0/24", Scenario: "crowdsecurity/ssh-bf"},
+	}
+	asrt.NoError(r.Sync(want))
+
+	eventually(t, srv, sshPath, "192.0.2.1")
+	eventually(t, srv, addressPath, "192.0.2.2")
+	eventually(t, srv, networkPath, "198.51.100.0/24")
+
+	ports, _ := srv.Lookup("firewall", "group", "port-group", "SSH_PORTS", "port")
+	asrt.Equal([]string{"22"}, ports.Values())
+	rule := []string{"firewall", "name", "WAN_IN", "rule", "10"}
+	asrt.Equal("drop", lookupValue(srv, append(rule, "action")...))
+	asrt.Equal("tcp", lookupValue(srv, append(rule, "protocol")...))
+	asrt.Equal("CROWDSEC_SSH", lookupValue(srv, append(rule, "source", "group", "address-group")...))
+	asrt.Equal("SSH_PORTS", lookupValue(srv, append(rule, "destination", "group", "port-group")...))
+
+	// A reconnect finds the scope in place and sends nothing.
+	sets := srv.Requests(xedgeostest.EndpointSet)
+	r.ag = nil
+	asrt.NoError(r.Sync(want))
+	asrt.Equal(sets, srv.Requests(xedgeostest.EndpointSet))
+
+	asrt.NoError(r.Sync(want[1:]))
+	eventually(t, srv, sshPath)
+	eventually(t, srv, addressPath, "192.0.2.2")
+
+	// A full ban takes precedence over a longer scoped one.
+	now := time.Now()
+	asrt.NoError(r.Sync([]decisions.Entry{
+		{Value: "192.0.2.3", ID: 1, Scenario: "crowdsecurity/http-probing", Until: now.Add(time.Hour)},
+		{Value: "192.0.2.3", ID: 2, Scenario: "crowdsecurity/ssh-bf", Until: now.Add(24 * time.Hour)},
+		{Value: "192.0.2.4", ID: 3, Scenario: "crowdsecurity/ssh-bf", Until: now.Add(time.Hour)},
+	}))
+	eventually(t, srv, addressPath, "192.0.2.3")
+	eventually(t, srv, sshPath, "192.0.2.4")
+}
+
+func TestRouterScopeRuleInUse(t *testing.T) {
+	asrt := assert.New(t)
+	srv := scopeServer(map[string]any{"10": map[string]any{"action": "accept"}})
+	defer srv.Close()
+
+	r := newTestRouter(t, srv)
+	r.Scopes = []Scope{sshScope}
+	asrt.ErrorContains(r.Sync(nil), "rule 10 in WAN_IN is already in use")
+}
@@ -0,0 +1,146 @@
+package bouncer
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jacobalberty/cs-edgeos-bouncer/pkg/xedgeos"
+)
+
+// Service ties scenarios to a port forward so attackers are only blocked from
+// the forwarded service. The forward is looked up by its original port when
+// the router connects and again once the lookup is older than serviceTTL, so
+// changes to the port forwarding table are picked up while the router stays
+// healthy.
+type Service struct {
+	// Scenarios match any decision whose scenario contains one of them.
+	Scenarios []string
+	// Port is the original (WAN side) port of the forward.
+	Port     string
+	Group    string
+	Rulesets []string
+	Rule     int
+}
+
+// serviceTTL is how long the port forwarding table is used before it is read
+// again.
+const serviceTTL = 5 * time.Minute
+
+// group returns the service's address group, defaulting to one named after
+// its port.
+func (svc Service) group() string {
+	if svc.Group == "" {
+		return "CROWDSEC_PF_" + svc.Port
+	}
+
+	return svc.Group
+}
+
+// resolveServices builds a scope for every service from the router's port
+// forwarding table. Forwarded traffic is filtered after DNAT so the rules
+// match the internal address and port the forward points at. The groups of
+// services whose forward has gone are noted in staleServices for
+// clearServices.
+func (r *Router) resolveServices() error {
+	res, err := r.client.PortForwards()
+	if err != nil {
+		return err
+	}
+	if !res.Success {
+		return fmt.Errorf("loading port forwards: %s", res.Error)
+	}
+
+	var (
+		scopes []Scope
+		stale  []string
+	)
+	for _, svc := range r.Services {
+		pf, ok := findForward(res.Feature.Data.Rules, svc.Port)
+		if !ok {
+			r.log.Warn("no port forward for port, its scenarios will be blocked outright", "port", svc.Port)
+			stale = append(stale, svc.group())
+			continue
+		}
+
+		rulesets := svc.Rulesets
+		if len(rulesets) == 0 {
+			rulesets = []string{"WAN_IN"}
+		}
+
+		scopes = append(scopes, Scope{
+			Name:      "CrowdSec " + pf.Description,
+			Scenarios: svc.Scenarios,
+			Group:     svc.group(),
+			Protocol:  pf.Protocol,
+			Rulesets:  rulesets,
+			Rule:      svc.Rule,
+			Address:   pf.IPTo,
+			Port:      pf.PortTo,
+			service:   true,
+		})
+	}
+	r.serviceScopes, r.staleServices, r.servicesLoaded = scopes, stale, time.Now()
+
+	return nil
+}
+
+// reloadServices reads the port forwarding table again if it is stale and
+// brings the services' groups and rules in line with it.
+func (r *Router) reloadServices() error {
+	if len(r.Services) == 0 || time.Since(r.servicesLoaded) < serviceTTL {
+		return nil
+	}
+	if err := r.resolveServices(); err != nil {
+		return err
+	}
+	if err := r.clearServices(r.staleServices); err != nil {
+		return err
+	}
+	if err := r.ensureScopes(); err != nil {
+		return err
+	}
+
+	return r.refresh()
+}
+
+// clearServices empties the address groups of services that no longer
+// resolve, so their addresses are not left blocked from a forward that has
+// gone. The groups and their rules are kept; an empty group matches nothing.
+func (r *Router) clearServices(groups []string) error {
+	if len(groups) == 0 {
+		return nil
+	}
+	res, err := r.client.GetPath("firewall", "group")
+	if err != nil {
+		return err
+	}
+	ag, err := xedgeos.NewAddressGroups(res)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range groups {
+		inUse := slices.ContainsFunc(r.allScopes(), func(s Scope) bool { return s.Group == name })
+		group, err := ag.GetGroup(name)
+		if inUse || err != nil || len(group.Address) == 0 {
+			continue
+		}
+		r.log.Info("emptying group of removed port forward", "group", name, "entries", len(group.Address))
+		if _, err := r.client.Delete(group.DeleteData(nil)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func findForward(rules []xedgeos.PortForward, port string) (xedgeos.PortForward, bool) {
+	for _, pf := range rules {
+		if pf.PortFrom == port {
+			return pf, true
+		}
+	}
+
+	return xedgeos.PortForward{}, false
+}
@@ -0,0 +1,85 @@
+package bouncer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jacobalberty/cs-edgeos-bouncer/internal/decisions"
+	"github.com/jacobalberty/cs-edgeos-bouncer/pkg/xedgeos"
+	"github.com/stretchr/testify/assert"
+)
+
+const servicePath = "firewall group address-group CROWDSEC_PF_2222 address"
+
+var sshForward = xedgeos.PortForward{
+	PortFrom:    "2222",
+	PortTo:      "22",
+	IPTo:        "192.168.1.10",
+	Protocol:    xedgeos.ProtocolTCP,
+	Description: "ssh",
+}
+
+func TestServiceMatchesRule(t *testing.T) {
+	asrt := assert.New(t)
+	s := Scope{Group: "CROWDSEC_PF_2222", Protocol: "tcp", Address: "192.168.1.10", Port: "22"}
+
+	asrt.True(s.matchesRule(s.rule()))
+
+	rule := s.rule()
+	rule.Destination = &xedgeos.RuleTarget{Address: "192.168.1.10", Port: "80"}
+	asrt.False(s.matchesRule(rule))
+
+	rule = s.rule()
+	rule.Destination.Group = &xedgeos.RuleGroup{PortGroup: "SSH_PORTS"}
+	asrt.False(s.matchesRule(rule))
+}
+
+func TestRouterServices(t *testing.T) {
+	asrt := assert.New(t)
+	srv := scopeServer(map[string]any{})
+	defer srv.Close()
+	srv.SetFeature(xedgeos.PortForwarding, xedgeos.PortForwards{Rules: []xedgeos.PortForward{sshForward}})
+
+	r := newTestRouter(t, srv)
+	r.Services = []Service{{Scenarios: []string{"ssh-bf"}, Port: "2222", Rule: 20}}
+
+	want := []decisions.Entry{
+		{Value: "192.0.2.1", Scenario: "crowdsecurity/ssh-bf"},
+		{Value: "192.0.2.2", Scenario: "crowdsecurity/http-probing"},
+	}
+	asrt.NoError(r.Sync(want))
+	eventually(t, srv, servicePath, "192.0.2.1")
+	eventually(t, srv, addressPath, "192.0.2.2")
+
+	rule := []string{"firewall", "name", "WAN_IN", "rule", "20"}
+	asrt.Equal("drop", lookupValue(srv, append(rule, "action")...))
+	asrt.Equal("CrowdSec ssh", lookupValue(srv, append(rule, "description")...))
+	asrt.Equal("tcp", lookupValue(srv, append(rule, "protocol")...))
+	asrt.Equal("CROWDSEC_PF_2222", lookupValue(srv, append(rule, "source", "group", "address-group")...))
+	asrt.Equal("192.168.1.10", lookupValue(srv, append(rule, "destination", "address")...))
+	asrt.Equal("22", lookupValue(srv, append(rule, "destination", "port")...))
+
+	// A changed forward is picked up without a reconnect once the table is
+	// stale, and the bouncer's own rule follows it.
+	moved := sshForward
+	moved.IPTo, moved.PortTo, moved.Protocol = "192.168.1.11", "2022", xedgeos.ProtocolTCPUDP
+	srv.SetFeature(xedgeos.PortForwarding, xedgeos.PortForwards{Rules: []xedgeos.PortForward{moved}})
+	asrt.NoError(r.Sync(want))
+	asrt.Equal("192.168.1.10", lookupValue(srv, append(rule, "destination", "address")...))
+	r.servicesLoaded = time.Time{}
+	asrt.NoError(r.Sync(want))
+	asrt.Equal("192.168.1.11", lookupValue(srv, append(rule, "destination", "address")...))
+	asrt.Equal("2022", lookupValue(srv, append(rule, "destination", "port")...))
+	asrt.Equal("tcp_udp", lookupValue(srv, append(rule, "protocol")...))
+	eventually(t, srv, servicePath, "192.0.2.1")
+
+	// Once the forward is removed the service's group is emptied and its
+	// scenarios are blocked outright.
+	srv.SetFeature(xedgeos.PortForwarding, xedgeos.PortForwards{})
+	r.ag = nil
+	asrt.NoError(r.Sync(want))
+	eventually(t, srv, servicePath)
+	eventually(t, srv, addressPath, "192.0.2.1", "192.0.2.2")
+	_, ok := srv.Lookup("firewall", "group", "address-group", "CROWDSEC_PF_2222")
+	asrt.True(ok)
+}
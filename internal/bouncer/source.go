@@ -0,0 +1,27 @@
+package bouncer
+
+import (
+	"context"
+
+	"github.com/crowdsecurity/crowdsec/pkg/models"
+	csbouncer "github.com/crowdsecurity/go-cs-bouncer"
+)
+
+// Source delivers decisions from LAPI.
+type Source interface {
+	// Run streams decisions until ctx is cancelled. The decisions channel
+	// may be closed if the source gives up.
+	Run(ctx context.Context)
+	// Decisions returns the channel decisions are delivered on.
+	Decisions() <-chan *models.DecisionsStreamResponse
+}
+
+// StreamSource adapts a csbouncer.StreamBouncer, which must already be
+// initialised, to a Source.
+type StreamSource struct {
+	*csbouncer.StreamBouncer
+}
+
+func (s StreamSource) Decisions() <-chan *models.DecisionsStreamResponse {
+	return s.Stream
+}
@@ -0,0 +1,168 @@
+package bouncer
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+
+	"github.com/jacobalberty/cs-edgeos-bouncer/pkg/xedgeos"
+)
+
+// groupRef is one side of a firewall rule that matches on an address group.
+type groupRef struct {
+	ruleset string
+	rule    int
+	side    string
+}
+
+func (g groupRef) path() []string {
+	return []string{"firewall", "name", g.ruleset, "rule", strconv.Itoa(g.rule), g.side, "group", "address-group"}
+}
+
+// findRefs returns every rule source or destination that matches on the
+// address group, in ruleset and rule order.
+func findRefs(rulesets *xedgeos.RulesetCollection, group string) []groupRef {
+	var out []groupRef
+	for _, name := range slices.Sorted(maps.Keys(*rulesets)) {
+		rs := (*rulesets)[name]
+		for _, n := range slices.Sorted(maps.Keys(rs.Rules)) {
+			rule := rs.Rules[n]
+			if matchesGroup(rule.Source, group) {
+				out = append(out, groupRef{name, n, "source"})
+			}
+			if matchesGroup(rule.Destination, group) {
+				out = append(out, groupRef{name, n, "destination"})
+			}
+		}
+	}
+
+	return out
+}
+
+func matchesGroup(t *xedgeos.RuleTarget, group string) bool {
+	return t != nil && t.Group != nil && t.Group.AddressGroup == group
+}
+
+// activeGroup returns whichever of Group and SwapGroup the firewall rules
+// currently match on.
+func (r *Router) activeGroup() string {
+	if r.swapped {
+		return r.SwapGroup
+	}
+
+	return r.Group
+}
+
+// standbyGroup returns the group that is filled on the next swap.
+func (r *Router) standbyGroup() string {
+	if r.swapped {
+		return r.Group
+	}
+
+	return r.SwapGroup
+}
+
+// ensureSwapGroup creates the swap group if it is missing.
+func (r *Router) ensureSwapGroup() error {
+	res, err := r.client.GetPath("firewall", "group")
+	if err != nil {
+		return err
+	}
+	ag, err := xedgeos.NewAddressGroups(res)
+	if err != nil {
+		return err
+	}
+	if _, err := ag.GetGroup(r.SwapGroup); err == nil {
+		return nil
+	}
+
+	r.log.Info("creating group", "group", r.SwapGroup)
+	_, err = r.client.Set(ag.GetCreateData(&xedgeos.AddressGroup{
+		Name:        r.SwapGroup,
+		Description: fmt.Sprintf("Standby for %s", r.Group),
+	}))
+
+	return err
+}
+
+// resolveSwap works out which of the two groups is live from the rules that
+// reference them.
+func (r *Router) resolveSwap() error {
+	res, err := r.client.GetPath("firewall", "name")
+	if err != nil {
+		return err
+	}
+	rulesets, err := xedgeos.NewRulesets(res)
+	if err != nil {
+		return err
+	}
+	main, swap := findRefs(rulesets, r.Group), findRefs(rulesets, r.SwapGroup)
+	if len(main) > 0 && len(swap) > 0 {
+		return fmt.Errorf("rules reference both %s and %s", r.Group, r.SwapGroup)
+	}
+	r.swapped = len(swap) > 0
+	if len(main) == 0 && len(swap) == 0 {
+		r.log.Warn("no rules reference either group, large changes will replace the group in place", "group", r.Group, "standby", r.SwapGroup)
+	}
+
+	return nil
+}
+
+// swap fills the standby group with the change's values, repoints every rule
+// at it in a single batch and then clears the previously live group, so the
+// rules never match on a partially filled group. It returns the group that
+// now holds the values.
+func (r *Router) swap(c groupChange) (string, error) {
+	from, to := r.activeGroup(), r.standbyGroup()
+
+	res, err := r.client.GetPath("firewall", "name")
+	if err != nil {
+		return to, err
+	}
+	rulesets, err := xedgeos.NewRulesets(res)
+	if err != nil {
+		return to, err
+	}
+	refs := findRefs(rulesets, from)
+	if len(refs) == 0 {
+		// Nothing to repoint, so swapping would leave the new values in a
+		// group no rule uses.
+		r.log.Warn("no rules reference group, replacing it in place", "group", from)
+		return from, r.replace(c)
+	}
+
+	standby := &xedgeos.AddressGroup{Name: to}
+	var fill xedgeos.BatchData
+	if len((*r.ag)[to].Address) > 0 {
+		fill.Delete = standby.DeleteData(nil)
+	}
+	if len(c.values) > 0 {
+		fill.Set = standby.SetData(c.values)
+	}
+	if fill.Delete != nil || fill.Set != nil {
+		if _, err := r.client.Batch(fill); err != nil {
+			return to, err
+		}
+	}
+
+	var flip []map[string]any
+	for _, ref := range refs {
+		flip = append(flip, xedgeos.SetData(ref.path(), to))
+	}
+	if _, err := r.client.Batch(xedgeos.BatchData{Set: xedgeos.MergeData(flip...)}); err != nil {
+		return to, err
+	}
+	r.swapped = !r.swapped
+	r.log.Info("rules swapped", "rules", len(refs), "group", to)
+
+	// The rules no longer use the old group and the next swap empties it
+	// before filling it, so failing to clear it now is not fatal.
+	if c.old > 0 {
+		if _, err := r.client.Delete((&xedgeos.AddressGroup{Name: from}).DeleteData(nil)); err != nil {
+			r.log.Warn("unable to clear old group", "group", from, "err", err)
+		}
+	}
+
+	return to, nil
+}
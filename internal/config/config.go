@@ -1,15 +1,30 @@
 package config
 
-import "github.com/kelseyhightower/envconfig"
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+)
 
 type Config struct {
-	CSApi CSApiConfig `envconfig:"CS"`
-	ERApi ERApiConfig `envconfig:"ER"`
+	CSApi   CSApiConfig   `envconfig:"CS"`
+	ERApi   ERApiConfig   `envconfig:"ER"`
+	State   StateConfig   `envconfig:"STATE"`
+	Metrics MetricsConfig `envconfig:"METRICS"`
+	Sync    SyncConfig    `envconfig:"SYNC"`
+	Log     LogConfig     `envconfig:"LOG"`
+	Audit   AuditConfig   `envconfig:"AUDIT"`
 }
 
 type CSApiConfig struct {
 	Key string `envconfig:"TOKEN"`
 	Url string `envconfig:"URL"`
+
+	// UpdateFrequency is how often the decision stream is polled.
+	UpdateFrequency time.Duration `envconfig:"UPDATE_FREQUENCY" default:"20s"`
 }
 
 type ERApiConfig struct {
@@ -17,6 +32,229 @@ type ERApiConfig struct {
 	Pass  string `envconfig:"PASS"`
 	Url   string `envconfig:"URL"`
 	Group string `envconfig:"GROUP"`
+
+	// Prefix decisions are only applied when a network group is set.
+	NetworkGroup     string `envconfig:"NETWORK_GROUP"`
+	IPv6NetworkGroup string `envconfig:"IPV6_NETWORK_GROUP"`
+
+	InsecureSkipVerify bool   `envconfig:"INSECURE_SKIP_VERIFY" default:"true"`
+	CAFile             string `envconfig:"CA_FILE"`
+
+	// Routers lists additional routers as a JSON array. Options left unset
+	// are inherited from the ER_ settings above.
+	Routers RouterList `envconfig:"ROUTERS"`
+
+	// MaxEntries caps each group on the router, EvictionPolicy decides
+	// which entries are kept.
+	MaxEntries     int    `envconfig:"MAX_ENTRIES"`
+	EvictionPolicy string `envconfig:"EVICTION_POLICY" default:"origin,duration,recent"`
+
+	ExpiryInterval time.Duration `envconfig:"EXPIRY_INTERVAL" default:"1m"`
+
+	KeepUnmanaged bool `envconfig:"KEEP_UNMANAGED"`
+
+	// BatchSize is the number of values sent per request. Setting
+	// BatchTarget adapts it, up to BatchMax, to keep each commit under
+	// the target duration.
+	BatchSize   int           `envconfig:"BATCH_SIZE" default:"50"`
+	BatchMax    int           `envconfig:"BATCH_MAX" default:"1000"`
+	BatchTarget time.Duration `envconfig:"BATCH_TARGET"`
+	// ReplaceThreshold replaces a group's whole member list in one commit
+	// when more than this many values change. Zero disables it.
+	ReplaceThreshold int `envconfig:"REPLACE_THRESHOLD" default:"1000"`
+	// SwapGroup is a standby address group. When set, changes beyond
+	// ReplaceThreshold fill it and repoint the rules at it instead.
+	SwapGroup string `envconfig:"SWAP_GROUP"`
+
+	AllowlistDHCP bool `envconfig:"ALLOWLIST_DHCP"`
+	// AllowlistLAN skips decisions for the subnets of the interfaces in
+	// AllowlistLANInterfaces, e.g. "switch0,eth1.10".
+	AllowlistLAN           bool     `envconfig:"ALLOWLIST_LAN"`
+	AllowlistLANInterfaces []string `envconfig:"ALLOWLIST_LAN_INTERFACES"`
+
+	Bootstrap         bool     `envconfig:"BOOTSTRAP"`
+	GroupDescription  string   `envconfig:"GROUP_DESCRIPTION" default:"CrowdSec bouncer"`
+	BootstrapRulesets []string `envconfig:"BOOTSTRAP_RULESETS" default:"WAN_IN,WAN_LOCAL"`
+	BootstrapRule     int      `envconfig:"BOOTSTRAP_RULE" default:"1"`
+
+	// Scopes lists port scoped blocks as a JSON array.
+	Scopes ScopeList `envconfig:"SCOPES"`
+	// Services lists port forward scoped blocks as a JSON array.
+	Services ServiceList `envconfig:"SERVICES"`
+}
+
+type RouterConfig struct {
+	Name  string `json:"name"`
+	User  string `json:"user"`
+	Pass  string `json:"pass"`
+	Url   string `json:"url"`
+	Group string `json:"group"`
+
+	NetworkGroup     string `json:"network_group"`
+	IPv6NetworkGroup string `json:"ipv6_network_group"`
+
+	InsecureSkipVerify *bool  `json:"insecure_skip_verify"`
+	CAFile             string `json:"ca_file"`
+}
+
+// RouterList decodes a JSON array of routers from the environment.
+type RouterList []RouterConfig
+
+func (l *RouterList) Decode(v string) error {
+	return json.Unmarshal([]byte(v), l)
+}
+
+// AllRouters returns every router the bouncer should manage: the one set by
+// ER_URL (if any) followed by ER_ROUTERS, with defaults filled in.
+func (c ERApiConfig) AllRouters() []RouterConfig {
+	var out []RouterConfig
+	if c.Url != "" {
+		out = append(out, RouterConfig{
+			User:  c.User,
+			Pass:  c.Pass,
+			Url:   c.Url,
+			Group: c.Group,
+
+			NetworkGroup:     c.NetworkGroup,
+			IPv6NetworkGroup: c.IPv6NetworkGroup,
+		})
+	}
+	out = append(out, c.Routers...)
+
+	for i := range out {
+		r := &out[i]
+		if r.User == "" {
+			r.User = c.User
+		}
+		if r.Pass == "" {
+			r.Pass = c.Pass
+		}
+		if r.Group == "" {
+			r.Group = c.Group
+		}
+		if r.NetworkGroup == "" {
+			r.NetworkGroup = c.NetworkGroup
+		}
+		if r.IPv6NetworkGroup == "" {
+			r.IPv6NetworkGroup = c.IPv6NetworkGroup
+		}
+		if r.InsecureSkipVerify == nil {
+			r.InsecureSkipVerify = &c.InsecureSkipVerify
+		}
+		if r.CAFile == "" {
+			r.CAFile = c.CAFile
+		}
+		if r.Name == "" {
+			r.Name = r.Url
+			if u, err := url.Parse(r.Url); err == nil && u.Host != "" {
+				r.Name = u.Host
+			}
+		}
+	}
+
+	return out
+}
+
+type ScopeConfig struct {
+	Name      string   `json:"name"`
+	Scenarios []string `json:"scenarios"`
+	Group     string   `json:"group"`
+	PortGroup string   `json:"port_group"`
+	Ports     []string `json:"ports"`
+	Protocol  string   `json:"protocol"`
+	Rulesets  []string `json:"rulesets"`
+	Rule      int      `json:"rule"`
+}
+
+// ScopeList decodes a JSON array of scopes from the environment.
+type ScopeList []ScopeConfig
+
+func (l *ScopeList) Decode(v string) error {
+	if err := json.Unmarshal([]byte(v), l); err != nil {
+		return err
+	}
+	for i := range *l {
+		s := &(*l)[i]
+		if s.Protocol == "" {
+			s.Protocol = "tcp_udp"
+		}
+		if s.Name == "" {
+			s.Name = s.Group
+		}
+		if s.Group == "" || s.PortGroup == "" || s.Rule == 0 || len(s.Ports) == 0 {
+			return fmt.Errorf("scope %q needs a group, port_group, ports and rule", s.Name)
+		}
+	}
+
+	return nil
+}
+
+type ServiceConfig struct {
+	Scenarios []string `json:"scenarios"`
+	Port      string   `json:"port"`
+	Group     string   `json:"group"`
+	Rulesets  []string `json:"rulesets"`
+	Rule      int      `json:"rule"`
+}
+
+// ServiceList decodes a JSON array of services from the environment.
+type ServiceList []ServiceConfig
+
+func (l *ServiceList) Decode(v string) error {
+	if err := json.Unmarshal([]byte(v), l); err != nil {
+		return err
+	}
+	for _, s := range *l {
+		if s.Port == "" || s.Rule == 0 {
+			return fmt.Errorf("service %v needs a port and rule", s.Scenarios)
+		}
+	}
+
+	return nil
+}
+
+type StateConfig struct {
+	File string `envconfig:"FILE"`
+}
+
+type MetricsConfig struct {
+	Addr string `envconfig:"ADDR"`
+}
+
+// SyncConfig controls how decision changes are coalesced before being
+// pushed to the routers.
+type SyncConfig struct {
+	// Debounce waits for this long without changes before pushing.
+	Debounce time.Duration `envconfig:"DEBOUNCE" default:"2s"`
+	// MaxLatency pushes no later than this after the first pending change,
+	// even if changes keep arriving.
+	MaxLatency time.Duration `envconfig:"MAX_LATENCY" default:"10s"`
+	// MaxBatch pushes as soon as this many changes are pending. Zero
+	// disables it.
+	MaxBatch int `envconfig:"MAX_BATCH" default:"1000"`
+}
+
+// LogConfig controls the bouncer's log output.
+type LogConfig struct {
+	// Level is one of debug, info, warn or error. At debug every request
+	// to the routers is logged, with credentials redacted.
+	Level string `envconfig:"LEVEL" default:"info"`
+	// Format is text or json.
+	Format string `envconfig:"FORMAT" default:"text"`
+}
+
+// AuditConfig controls the audit log of addresses added to and removed from
+// the routers.
+type AuditConfig struct {
+	// File is where the audit log is written. Empty disables it.
+	File string `envconfig:"FILE"`
+	// MaxSize is the size in megabytes at which the file is rotated.
+	MaxSize int `envconfig:"MAX_SIZE" default:"100"`
+	// MaxBackups and MaxAge, in days, limit how many rotated files are
+	// kept. Zero keeps them all.
+	MaxBackups int  `envconfig:"MAX_BACKUPS"`
+	MaxAge     int  `envconfig:"MAX_AGE"`
+	Compress   bool `envconfig:"COMPRESS"`
 }
 
 func GetConfig() (*Config, error) {
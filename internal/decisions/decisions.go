@@ -0,0 +1,249 @@
+// Package decisions tracks the CrowdSec decisions the bouncer wants applied to
+// the router and decides which of them fit when a group is capped.
+package decisions
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/crowdsecurity/crowdsec/pkg/models"
+)
+
+// Entry is a single address the bouncer wants on the router along with the
+// decision metadata that put it there.
+type Entry struct {
+	Value    string    `json:"value"`
+	ID       int64     `json:"id,omitempty"`
+	Origin   string    `json:"origin,omitempty"`
+	Scenario string    `json:"scenario,omitempty"`
+	Until    time.Time `json:"until,omitempty"`
+	Added    time.Time `json:"added"`
+}
+
+// FromModel converts a LAPI decision into an Entry. The expiry is computed
+// from the decision duration relative to now, and the value is normalized
+// when it is an address or prefix.
+func FromModel(d *models.Decision, now time.Time) Entry {
+	e := Entry{
+		ID:    d.ID,
+		Added: now,
+	}
+	if d.Value != nil {
+		e.Value = *d.Value
+		if v, err := Normalize(e.Value); err == nil {
+			e.Value = v
+		}
+	}
+	if d.Origin != nil {
+		e.Origin = *d.Origin
+	}
+	if d.Scenario != nil {
+		e.Scenario = *d.Scenario
+	}
+	if d.Duration != nil {
+		if dur, err := time.ParseDuration(*d.Duration); err == nil {
+			e.Until = now.Add(dur)
+		}
+	}
+
+	return e
+}
+
+// Local reports whether the entry came from this CrowdSec installation rather
+// than from the community blocklists.
+func (e Entry) Local() bool {
+	return e.Origin != "CAPI" && e.Origin != "lists"
+}
+
+// Criterion orders two entries, returning a negative number when a should be
+// kept in preference to b.
+type Criterion func(a, b Entry) int
+
+// Policy is an ordered list of criteria used to decide which entries survive
+// when a group is over capacity. Later criteria only break ties of earlier
+// ones.
+type Policy []Criterion
+
+// ByOrigin prefers locally generated decisions over community lists.
+func ByOrigin(a, b Entry) int {
+	switch {
+	case a.Local() == b.Local():
+		return 0
+	case a.Local():
+		return -1
+	default:
+		return 1
+	}
+}
+
+// ByDuration prefers the entry with the longest remaining duration.
+func ByDuration(a, b Entry) int {
+	return b.Until.Compare(a.Until)
+}
+
+// ByRecent prefers the most recently added entry.
+func ByRecent(a, b Entry) int {
+	return b.Added.Compare(a.Added)
+}
+
+var criteria = map[string]Criterion{
+	"origin":   ByOrigin,
+	"duration": ByDuration,
+	"recent":   ByRecent,
+}
+
+// ParsePolicy builds a Policy from a comma separated list of criteria names
+// ("origin", "duration", "recent").
+func ParsePolicy(s string) (Policy, error) {
+	var p Policy
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		c, ok := criteria[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown eviction criterion %q", name)
+		}
+		p = append(p, c)
+	}
+
+	return p, nil
+}
+
+func (p Policy) compare(a, b Entry) int {
+	for _, c := range p {
+		if r := c(a, b); r != 0 {
+			return r
+		}
+	}
+
+	return strings.Compare(a.Value, b.Value)
+}
+
+// Cap returns, for each value, the entry the policy ranks highest and keeps
+// the max highest ranked of those, sorted by value. The evicted rest are
+// returned highest priority first. A max of zero or less keeps every value.
+func (p Policy) Cap(entries []Entry, max int) (kept, evicted []Entry) {
+	ranked := slices.SortedFunc(slices.Values(entries), p.compare)
+	seen := make(map[string]bool, len(ranked))
+	ranked = slices.DeleteFunc(ranked, func(e Entry) bool {
+		dup := seen[e.Value]
+		seen[e.Value] = true
+		return dup
+	})
+	if max <= 0 || len(ranked) <= max {
+		max = len(ranked)
+	}
+
+	kept, evicted = ranked[:max:max], ranked[max:]
+	slices.SortFunc(kept, func(a, b Entry) int {
+		return strings.Compare(a.Value, b.Value)
+	})
+
+	return kept, evicted
+}
+
+// Set is the collection of entries the bouncer would like on the router. A
+// value is kept for as long as any of its decisions is. The set is not
+// capped; each router caps its groups when pushing.
+type Set struct {
+	// entries holds every decision for a value, keyed by decision ID.
+	entries map[string]map[int64]Entry
+}
+
+// NewSet returns an empty Set.
+func NewSet() *Set {
+	return &Set{entries: map[string]map[int64]Entry{}}
+}
+
+// Add stores the entry, returning true if the set changed. When the decision
+// is already tracked the entry with the later expiry wins.
+func (s *Set) Add(e Entry) bool {
+	ds, has := s.entries[e.Value]
+	if !has {
+		ds = map[int64]Entry{}
+		s.entries[e.Value] = ds
+	}
+	cur, has := ds[e.ID]
+	if has && !e.Until.After(cur.Until) {
+		return false
+	}
+	if has {
+		e.Added = cur.Added
+	}
+	ds[e.ID] = e
+
+	return true
+}
+
+// Remove drops the decision with the given ID, returning true if it was
+// tracked. The value stays in the set while other decisions still ban it.
+// Decisions without an ID, such as those resumed from an older state file,
+// are only dropped once they expire.
+func (s *Set) Remove(value string, id int64) bool {
+	ds := s.entries[value]
+	if _, has := ds[id]; !has {
+		return false
+	}
+	delete(ds, id)
+	if len(ds) == 0 {
+		delete(s.entries, value)
+	}
+
+	return true
+}
+
+// Get returns the decision for value that lasts longest.
+func (s *Set) Get(value string) (Entry, bool) {
+	var (
+		best Entry
+		has  bool
+	)
+	for _, e := range s.entries[value] {
+		if !has || longer(e, best) {
+			best, has = e, true
+		}
+	}
+
+	return best, has
+}
+
+// longer reports whether a outlasts b. Entries without a known expiry last
+// forever.
+func longer(a, b Entry) bool {
+	switch {
+	case a.Until.IsZero() != b.Until.IsZero():
+		return a.Until.IsZero()
+	case !a.Until.Equal(b.Until):
+		return a.Until.After(b.Until)
+	default:
+		return a.ID < b.ID
+	}
+}
+
+// Expire removes every decision whose expiry is before now and returns them.
+// Decisions without a known expiry are left alone.
+func (s *Set) Expire(now time.Time) []Entry {
+	var expired []Entry
+	for v, ds := range s.entries {
+		for id, e := range ds {
+			if !e.Until.IsZero() && e.Until.Before(now) {
+				expired = append(expired, e)
+				delete(ds, id)
+			}
+		}
+		if len(ds) == 0 {
+			delete(s.entries, v)
+		}
+	}
+
+	return expired
+}
+
+// Len returns the number of values in the set.
+func (s *Set) Len() int {
+	return len(s.entries)
+}
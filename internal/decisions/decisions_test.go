@@ -0,0 +1,134 @@
+package decisions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func values(entries []Entry) []string {
+	out := make([]string, len(entries))
+	for i, e := range entries {
+		out[i] = e.Value
+	}
+
+	return out
+}
+
+func TestCap(t *testing.T) {
+	asrt := assert.New(t)
+	now := time.Now()
+
+	policy, err := ParsePolicy("origin,duration,recent")
+	asrt.NoError(err)
+
+	all := []Entry{
+		{Value: "1.1.1.1", Origin: "CAPI", Until: now.Add(time.Hour), Added: now},
+		{Value: "3.3.3.3", Origin: "lists", Until: now.Add(2 * time.Hour), Added: now},
+		{Value: "2.2.2.2", Origin: "crowdsec", Until: now.Add(time.Minute), Added: now},
+	}
+	kept, evicted := policy.Cap(all, 2)
+	asrt.Equal([]string{"2.2.2.2", "3.3.3.3"}, values(kept))
+	asrt.Equal([]string{"1.1.1.1"}, values(evicted))
+
+	// Evicted entries come back once there is room.
+	kept, evicted = policy.Cap(all[:2], 2)
+	asrt.Equal([]string{"1.1.1.1", "3.3.3.3"}, values(kept))
+	asrt.Empty(evicted)
+
+	kept, _ = policy.Cap(all, 0)
+	asrt.Len(kept, 3)
+}
+
+func TestAddKeepsLongest(t *testing.T) {
+	asrt := assert.New(t)
+	now := time.Now()
+
+	s := NewSet()
+	asrt.True(s.Add(Entry{Value: "1.1.1.1", Until: now.Add(time.Hour)}))
+	asrt.False(s.Add(Entry{Value: "1.1.1.1", Until: now.Add(time.Minute)}))
+	asrt.True(s.Add(Entry{Value: "1.1.1.1", Until: now.Add(2 * time.Hour)}))
+
+	e, ok := s.Get("1.1.1.1")
+	asrt.True(ok)
+	asrt.Equal(now.Add(2*time.Hour), e.Until)
+}
+
+func TestRemove(t *testing.T) {
+	asrt := assert.New(t)
+	now := time.Now()
+
+	s := NewSet()
+	asrt.True(s.Add(Entry{Value: "1.1.1.1", ID: 1, Until: now.Add(4 * time.Hour)}))
+	asrt.True(s.Add(Entry{Value: "1.1.1.1", ID: 2, Origin: "CAPI", Until: now.Add(7 * 24 * time.Hour)}))
+	e, _ := s.Get("1.1.1.1")
+	asrt.Equal(int64(2), e.ID)
+
+	// Deleting one decision leaves the value banned by the other.
+	asrt.True(s.Remove("1.1.1.1", 1))
+	asrt.False(s.Remove("1.1.1.1", 1))
+	asrt.Equal(1, s.Len())
+	asrt.True(s.Remove("1.1.1.1", 2))
+	asrt.Equal(0, s.Len())
+}
+
+func TestCapDuplicates(t *testing.T) {
+	asrt := assert.New(t)
+	now := time.Now()
+
+	policy, err := ParsePolicy("origin")
+	asrt.NoError(err)
+
+	// A value with several decisions takes one slot, ranked by its best.
+	kept, evicted := policy.Cap([]Entry{
+		{Value: "1.1.1.1", ID: 1, Origin: "CAPI", Until: now},
+		{Value: "1.1.1.1", ID: 2, Origin: "crowdsec", Until: now},
+		{Value: "2.2.2.2", ID: 3, Origin: "CAPI", Until: now},
+	}, 1)
+	if asrt.Len(kept, 1) {
+		asrt.Equal(int64(2), kept[0].ID)
+	}
+	asrt.Equal([]string{"2.2.2.2"}, values(evicted))
+}
+
+func TestParsePolicy(t *testing.T) {
+	asrt := assert.New(t)
+
+	_, err := ParsePolicy("origin,bogus")
+	asrt.Error(err)
+
+	p, err := ParsePolicy("recent")
+	asrt.NoError(err)
+	asrt.Len(p, 1)
+}
+
+func TestExpire(t *testing.T) {
+	asrt := assert.New(t)
+	now := time.Now()
+
+	s := NewSet()
+	s.Add(Entry{Value: "1.1.1.1", Until: now.Add(-time.Second)})
+	s.Add(Entry{Value: "2.2.2.2", Until: now.Add(time.Hour)})
+	s.Add(Entry{Value: "3.3.3.3"})
+
+	expired := s.Expire(now)
+	asrt.Len(expired, 1)
+	asrt.Equal("1.1.1.1", expired[0].Value)
+	asrt.Equal([]string{"2.2.2.2", "3.3.3.3"}, values(s.Entries()))
+}
+
+func TestSaveLoad(t *testing.T) {
+	asrt := assert.New(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	path := t.TempDir() + "/state.json"
+
+	s := NewSet()
+	asrt.NoError(s.Load(path))
+	s.Add(Entry{Value: "1.1.1.1", ID: 7, Origin: "crowdsec", Scenario: "crowdsecurity/ssh-bf", Until: now.Add(time.Hour), Added: now})
+	asrt.NoError(s.Save(path))
+
+	l := NewSet()
+	asrt.NoError(l.Load(path))
+	asrt.Equal(s.Entries(), l.Entries())
+}
@@ -0,0 +1,234 @@
+package decisions
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is a ban list format understood by Export and Import.
+type Format string
+
+// Supported ban list formats
+const (
+	// FormatText is one address or prefix per line. Blank lines and lines
+	// starting with # are ignored on import.
+	FormatText Format = "text"
+	// FormatCSV has a header row naming the columns value, id, origin,
+	// scenario, until and added. Only value is required on import.
+	FormatCSV Format = "csv"
+	// FormatJSON is an array of entries. The state file is also accepted on
+	// import.
+	FormatJSON Format = "json"
+)
+
+var csvHeader = []string{"value", "id", "origin", "scenario", "until", "added"}
+
+// ParseFormat returns the format named s.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatText, FormatCSV, FormatJSON:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown format %q", s)
+	}
+}
+
+// Export writes entries to w in the given format.
+func Export(w io.Writer, f Format, entries []Entry) error {
+	switch f {
+	case FormatText:
+		bw := bufio.NewWriter(w)
+		for _, e := range entries {
+			fmt.Fprintln(bw, e.Value)
+		}
+		return bw.Flush()
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		cw.Write(csvHeader)
+		for _, e := range entries {
+			cw.Write([]string{e.Value, formatID(e.ID), e.Origin, e.Scenario, formatTime(e.Until), formatTime(e.Added)})
+		}
+		cw.Flush()
+		return cw.Error()
+	case FormatJSON:
+		if entries == nil {
+			entries = []Entry{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	default:
+		return fmt.Errorf("unknown format %q", f)
+	}
+}
+
+// Import reads entries in the given format from r. Every value must be an
+// address or prefix, and duplicates are merged keeping the latest expiry.
+// Single IPv6 addresses can't go in an address group so they become /128
+// prefixes. The entries are returned sorted by value.
+func Import(r io.Reader, f Format) ([]Entry, error) {
+	var (
+		entries []Entry
+		err     error
+	)
+	switch f {
+	case FormatText:
+		entries, err = importText(r)
+	case FormatCSV:
+		entries, err = importCSV(r)
+	case FormatJSON:
+		entries, err = importJSON(r)
+	default:
+		err = fmt.Errorf("unknown format %q", f)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	set := NewSet()
+	for i, e := range entries {
+		if e.Value, err = Normalize(e.Value); err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i+1, err)
+		}
+		set.Add(e)
+	}
+
+	return set.Entries(), nil
+}
+
+// Normalize checks that value is an address or prefix, turning IPv6
+// addresses into /128 prefixes and unmapping IPv4-mapped ones.
+func Normalize(value string) (string, error) {
+	if addr, err := netip.ParseAddr(value); err == nil && addr.Zone() == "" {
+		if addr.Is4In6() {
+			return addr.Unmap().String(), nil
+		}
+		if addr.Is6() {
+			return netip.PrefixFrom(addr, 128).String(), nil
+		}
+		return value, nil
+	}
+	if _, err := netip.ParsePrefix(value); err == nil {
+		return value, nil
+	}
+
+	return "", fmt.Errorf("%q is not an address or prefix", value)
+}
+
+func importText(r io.Reader) ([]Entry, error) {
+	var out []Entry
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		out = append(out, Entry{Value: line})
+	}
+
+	return out, sc.Err()
+}
+
+func importCSV(r io.Reader) ([]Entry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	cols := map[string]int{}
+	for i, h := range header {
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := cols["value"]; !ok {
+		return nil, errors.New("csv header has no value column")
+	}
+
+	var out []Entry
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(name string) string {
+			if i, ok := cols[name]; ok && i < len(rec) {
+				return rec[i]
+			}
+			return ""
+		}
+
+		e := Entry{
+			Value:    field("value"),
+			Origin:   field("origin"),
+			Scenario: field("scenario"),
+		}
+		if id := field("id"); id != "" {
+			if e.ID, err = strconv.ParseInt(id, 10, 64); err != nil {
+				return nil, fmt.Errorf("id %q: %w", id, err)
+			}
+		}
+		if e.Until, err = parseTime(field("until")); err != nil {
+			return nil, err
+		}
+		if e.Added, err = parseTime(field("added")); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+}
+
+func importJSON(r io.Reader) ([]Entry, error) {
+	bs, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Entry
+	if trimmed := bytes.TrimSpace(bs); len(trimmed) > 0 && trimmed[0] == '{' {
+		var st state
+		err = json.Unmarshal(bs, &st)
+		out = st.Entries
+	} else {
+		err = json.Unmarshal(bs, &out)
+	}
+
+	return out, err
+}
+
+func formatID(id int64) string {
+	if id == 0 {
+		return ""
+	}
+
+	return strconv.FormatInt(id, 10)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,73 @@
+package decisions
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportImport(t *testing.T) {
+	asrt := assert.New(t)
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	entries := []Entry{
+		{Value: "198.51.100.1", ID: 42, Origin: "crowdsec", Scenario: "crowdsecurity/ssh-bf", Until: now.Add(4 * time.Hour), Added: now},
+		{Value: "203.0.113.0/24"},
+	}
+
+	for _, f := range []Format{FormatText, FormatCSV, FormatJSON} {
+		var buf bytes.Buffer
+		asrt.NoError(Export(&buf, f, entries))
+
+		got, err := Import(&buf, f)
+		asrt.NoError(err, f)
+		if f == FormatText {
+			asrt.Equal([]Entry{{Value: "198.51.100.1"}, {Value: "203.0.113.0/24"}}, got)
+		} else {
+			asrt.Equal(entries, got, f)
+		}
+	}
+
+	var buf bytes.Buffer
+	asrt.NoError(Export(&buf, FormatCSV, entries[:1]))
+	asrt.Equal("value,id,origin,scenario,until,added\n"+
+		"198.51.100.1,42,crowdsec,crowdsecurity/ssh-bf,2026-10-19T16:00:00Z,2026-10-19T12:00:00Z\n", buf.String())
+}
+
+func TestImport(t *testing.T) {
+	asrt := assert.New(t)
+
+	got, err := Import(strings.NewReader("# blocklist\n198.51.100.2\n\n198.51.100.1\n198.51.100.2\n"), FormatText)
+	asrt.NoError(err)
+	asrt.Equal([]Entry{{Value: "198.51.100.1"}, {Value: "198.51.100.2"}}, got)
+
+	got, err = Import(strings.NewReader("scenario,value\nssh-bf,198.51.100.1\n,198.51.100.1\n"), FormatCSV)
+	asrt.NoError(err)
+	asrt.Equal([]Entry{{Value: "198.51.100.1", Scenario: "ssh-bf"}}, got)
+
+	got, err = Import(strings.NewReader(`{"version":1,"entries":[{"value":"198.51.100.3"}]}`), FormatJSON)
+	asrt.NoError(err)
+	asrt.Equal([]Entry{{Value: "198.51.100.3"}}, got)
+
+	// Single IPv6 addresses become prefixes so they can be put in a group.
+	got, err = Import(strings.NewReader("2001:db8::1\n::ffff:198.51.100.4\n2001:db8::/64\n"), FormatText)
+	asrt.NoError(err)
+	asrt.Equal([]Entry{{Value: "198.51.100.4"}, {Value: "2001:db8::/64"}, {Value: "2001:db8::1/128"}}, got)
+
+	_, err = Import(strings.NewReader("198.51.100.1\nnot-an-ip\n"), FormatText)
+	asrt.Error(err)
+	_, err = Import(strings.NewReader("fe80::1%eth0\n"), FormatText)
+	asrt.Error(err)
+	_, err = Import(strings.NewReader("value,id\n198.51.100.1,abc\n"), FormatCSV)
+	asrt.Error(err)
+	_, err = Import(strings.NewReader("address\n198.51.100.1\n"), FormatCSV)
+	asrt.Error(err)
+	_, err = Import(strings.NewReader("value,until\n198.51.100.1,tomorrow\n"), FormatCSV)
+	asrt.Error(err)
+
+	_, err = ParseFormat("xml")
+	asrt.Error(err)
+}
@@ -0,0 +1,75 @@
+package decisions
+
+import "slices"
+
+// Ownership remembers which addresses in a router group were put there by the
+// bouncer, so entries added by hand to the same group can be left alone.
+type Ownership struct {
+	owned map[string]bool
+}
+
+// NewOwnership returns an Ownership that considers addrs to be bouncer-owned.
+func NewOwnership(addrs []string) *Ownership {
+	o := &Ownership{owned: map[string]bool{}}
+	for _, a := range addrs {
+		o.owned[a] = true
+	}
+
+	return o
+}
+
+// Unmanaged returns the addresses in current that the bouncer does not own.
+func (o *Ownership) Unmanaged(current []string) []string {
+	var out []string
+	for _, a := range current {
+		if !o.owned[a] {
+			out = append(out, a)
+		}
+	}
+
+	return out
+}
+
+// Merge returns the sorted union of the unmanaged addresses and the addresses
+// the bouncer wants.
+func Merge(unmanaged, want []string) []string {
+	out := slices.Concat(unmanaged, want)
+	slices.Sort(out)
+
+	return slices.Compact(out)
+}
+
+// Commit records that want has been pushed to the router. Addresses that were
+// already present as unmanaged entries stay unmanaged so removing the decision
+// later does not remove the hand-added entry.
+func (o *Ownership) Commit(want, unmanaged []string) {
+	skip := make(map[string]bool, len(unmanaged))
+	for _, a := range unmanaged {
+		skip[a] = true
+	}
+	o.owned = make(map[string]bool, len(want))
+	for _, a := range want {
+		if !skip[a] {
+			o.owned[a] = true
+		}
+	}
+}
+
+// Add records that the bouncer put addrs on the router.
+func (o *Ownership) Add(addrs ...string) {
+	for _, a := range addrs {
+		o.owned[a] = true
+	}
+}
+
+// Remove records that addrs are no longer on the router.
+func (o *Ownership) Remove(addrs ...string) {
+	for _, a := range addrs {
+		delete(o.owned, a)
+	}
+}
+
+// Owned reports whether the bouncer put addr on the router.
+func (o *Ownership) Owned(addr string) bool {
+	return o.owned[addr]
+}
@@ -0,0 +1,33 @@
+package decisions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOwnership(t *testing.T) {
+	asrt := assert.New(t)
+
+	o := NewOwnership([]string{"1.1.1.1"})
+	router := []string{"1.1.1.1", "10.0.0.1"}
+
+	unmanaged := o.Unmanaged(router)
+	asrt.Equal([]string{"10.0.0.1"}, unmanaged)
+
+	want := []string{"10.0.0.1", "2.2.2.2"}
+	asrt.Equal([]string{"10.0.0.1", "2.2.2.2"}, Merge(unmanaged, want))
+
+	o.Commit(want, unmanaged)
+	asrt.True(o.Owned("2.2.2.2"))
+	asrt.False(o.Owned("10.0.0.1"))
+	asrt.False(o.Owned("1.1.1.1"))
+
+	// Dropping the decision for a hand-added address keeps the address.
+	asrt.Equal([]string{"10.0.0.1"}, Merge(o.Unmanaged([]string{"10.0.0.1", "2.2.2.2"}), nil))
+
+	o.Add("3.3.3.3")
+	o.Remove("2.2.2.2")
+	asrt.True(o.Owned("3.3.3.3"))
+	asrt.False(o.Owned("2.2.2.2"))
+}
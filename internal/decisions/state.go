@@ -0,0 +1,89 @@
+package decisions
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const stateVersion = 1
+
+type state struct {
+	Version int     `json:"version"`
+	Entries []Entry `json:"entries"`
+}
+
+// Entries returns every tracked decision, sorted by value and then ID.
+func (s *Set) Entries() []Entry {
+	all := make([]Entry, 0, len(s.entries))
+	for _, ds := range s.entries {
+		for _, e := range ds {
+			all = append(all, e)
+		}
+	}
+	slices.SortFunc(all, func(a, b Entry) int {
+		return cmp.Or(strings.Compare(a.Value, b.Value), cmp.Compare(a.ID, b.ID))
+	})
+
+	return all
+}
+
+// Save writes the set to path. The file is written to a temporary file in the
+// same directory and renamed into place so a crash never leaves a partial
+// state file behind.
+func (s *Set) Save(path string) error {
+	bs, err := json.MarshalIndent(state{
+		Version: stateVersion,
+		Entries: s.Entries(),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(bs); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load adds the entries stored at path to the set. A missing file is not an
+// error, it simply means there is nothing to resume.
+func (s *Set) Load(path string) error {
+	bs, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var st state
+	if err := json.Unmarshal(bs, &st); err != nil {
+		return err
+	}
+	for _, e := range st.Entries {
+		s.Add(e)
+	}
+
+	return nil
+}
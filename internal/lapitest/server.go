@@ -0,0 +1,141 @@
+// Package lapitest provides an in-process fake CrowdSec LAPI serving the
+// decision stream, for testing the bouncer end to end without CrowdSec.
+package lapitest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+
+	"github.com/crowdsecurity/crowdsec/pkg/models"
+)
+
+// Server is a fake LAPI. Decisions added or deleted are handed out on the
+// next poll of /v1/decisions/stream, and a startup poll returns every active
+// decision as LAPI does.
+type Server struct {
+	*httptest.Server
+
+	APIKey string
+
+	mu      sync.Mutex
+	nextID  int64
+	active  []*models.Decision
+	added   []*models.Decision
+	deleted []*models.Decision
+	polls   int
+}
+
+// NewServer starts a fake LAPI that accepts apiKey.
+func NewServer(apiKey string) *Server {
+	s := &Server{APIKey: apiKey}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/decisions/stream", s.stream)
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// Decision returns a decision with the defaults a local ban would have.
+func Decision(value, typ string) *models.Decision {
+	var (
+		origin   = "crowdsec"
+		scenario = "crowdsecurity/ssh-bf"
+		scope    = "Ip"
+		duration = "4h"
+	)
+
+	return &models.Decision{
+		Origin:   &origin,
+		Scenario: &scenario,
+		Scope:    &scope,
+		Type:     &typ,
+		Value:    &value,
+		Duration: &duration,
+	}
+}
+
+// Ban returns a ban decision for value.
+func Ban(value string) *models.Decision {
+	return Decision(value, "ban")
+}
+
+// Add adds decisions, assigning IDs to any that don't have one.
+func (s *Server) Add(ds ...*models.Decision) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, d := range ds {
+		if d.ID == 0 {
+			s.nextID++
+			d.ID = s.nextID
+		}
+		s.active = append(s.active, d)
+		s.added = append(s.added, d)
+	}
+}
+
+// Delete deletes every active decision for the values.
+func (s *Server) Delete(values ...string) {
+	s.deleteFunc(func(d *models.Decision) bool {
+		return slices.Contains(values, *d.Value)
+	})
+}
+
+// DeleteID deletes the active decisions with the IDs.
+func (s *Server) DeleteID(ids ...int64) {
+	s.deleteFunc(func(d *models.Decision) bool {
+		return slices.Contains(ids, d.ID)
+	})
+}
+
+func (s *Server) deleteFunc(del func(d *models.Decision) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keep []*models.Decision
+	for _, d := range s.active {
+		if del(d) {
+			s.deleted = append(s.deleted, d)
+		} else {
+			keep = append(keep, d)
+		}
+	}
+	s.active = keep
+}
+
+// Polls returns the number of times the stream has been polled.
+func (s *Server) Polls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.polls
+}
+
+func (s *Server) stream(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Api-Key") != s.APIKey {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"message": "access forbidden"})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.polls++
+	res := models.DecisionsStreamResponse{
+		New:     s.added,
+		Deleted: s.deleted,
+	}
+	if r.URL.Query().Get("startup") == "true" {
+		res.New = s.active
+		res.Deleted = nil
+	}
+	s.added, s.deleted = nil, nil
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
@@ -2,15 +2,45 @@ package xedgeos
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"slices"
 )
 
+// GroupType is the kind of firewall group, as named in the EdgeOS
+// configuration.
+type GroupType string
+
+// Firewall group types
+const (
+	AddressGroupType     GroupType = "address-group"
+	NetworkGroupType     GroupType = "network-group"
+	IPv6NetworkGroupType GroupType = "ipv6-network-group"
+	PortGroupType        GroupType = "port-group"
+)
+
+// key returns the name of the multi-valued leaf holding the group's members.
+func (t GroupType) key() string {
+	switch t {
+	case NetworkGroupType, IPv6NetworkGroupType:
+		return "network"
+	case PortGroupType:
+		return "port"
+	default:
+		return "address"
+	}
+}
+
 type AddressGroupCollection map[string]AddressGroup
 
 type AddressGroup struct {
-	Name    string   `json:"-"`
-	Address []string `json:"address,omitempty"`
+	Name        string   `json:"-"`
+	Description string   `json:"description,omitempty"`
+	Address     []string `json:"address,omitempty"`
+}
+
+// path returns the configuration path of the group, followed by elem.
+func (a *AddressGroup) path(elem ...string) []string {
+	return append([]string{"firewall", "group", string(AddressGroupType), a.Name}, elem...)
 }
 
 func (a *AddressGroup) Reset() {
@@ -43,68 +73,45 @@ func (a *AddressGroup) Remove(ip string) bool {
 	return true
 }
 
+// SetData returns the data needed to add values to the group.
+func (a *AddressGroup) SetData(values []string) map[string]any {
+	return SetData(a.path("address"), values)
+}
+
+// DeleteData returns the data needed to remove values from the group.
+func (a *AddressGroup) DeleteData(values []string) map[string]any {
+	return DeleteData(a.path("address"), values...)
+}
+
 // This function compares the Address Group from our colleciton with the input group
-// And returns data that does not exist in the input but does exist in our collection
-// To be used for set it returns them in batches of 50
+// And returns data that does not exist in our collection but does exist in the input
+// To be used for set it returns them in batches of DefaultBatchSize
 func (a *AddressGroupCollection) GetSetData(group *AddressGroup) ([]map[string]any, error) {
-	var (
-		batchSize = 50
-		setGroup  = AddressGroup{}
-	)
+	return a.GetSetBatches(group, DefaultBatchSize)
+}
 
+// GetSetBatches is GetSetData with size addresses per batch.
+func (a *AddressGroupCollection) GetSetBatches(group *AddressGroup, size int) ([]map[string]any, error) {
 	// Get the group from the collection
 	ourGroup, ok := (*a)[group.Name]
 	if !ok {
 		return nil, fmt.Errorf("group %s not found", group.Name)
 	}
 
-	// Find the difference between the two groups
-	for _, ip := range group.Address {
-		if !ourGroup.Contains(ip) {
-			setGroup.Address = append(setGroup.Address, ip)
-		}
-	}
-
-	batches := len(setGroup.Address) / batchSize
-	if len(setGroup.Address)%batchSize != 0 {
-		batches++
-	}
-	// Batch the results out
-	data := make([]map[string]any, batches)
-	for i := 0; i < batches; i++ {
-		start := i * batchSize
-		end := (i + 1) * batchSize
-		if end > len(setGroup.Address) {
-			end = len(setGroup.Address)
-		}
-
-		data[i] = map[string]any{
-			"firewall": map[string]any{
-				"group": map[string]any{
-					"address-group": map[string]any{
-						group.Name: map[string]any{
-							"address": setGroup.Address[start:end],
-						},
-					},
-				},
-			},
-		}
-	}
-
-	return data, nil
+	return setData(group.SetData, ourGroup.Address, group.Address, size), nil
 }
 
 // This function compares the Address Group from our colleciton with the input group
 // And returns data that does not exist in the input but does exist in our collection
-// To be used for deletion it returns htem in batches of 50
+// To be used for deletion it returns them in batches of DefaultBatchSize
 func (a *AddressGroupCollection) GetDeleteData(group *AddressGroup) ([]map[string]any, error) {
-	var (
-		batchSize = 50
-		delGroup  = AddressGroup{}
-	)
+	return a.GetDeleteBatches(group, DefaultBatchSize)
+}
 
+// GetDeleteBatches is GetDeleteData with size addresses per batch.
+func (a *AddressGroupCollection) GetDeleteBatches(group *AddressGroup, size int) ([]map[string]any, error) {
 	if !slices.IsSorted(group.Address) {
-		log.Printf("sorting %s\n", group.Name)
+		slog.Debug("sorting group", "group", group.Name)
 		slices.Sort(group.Address)
 	}
 
@@ -114,39 +121,13 @@ func (a *AddressGroupCollection) GetDeleteData(group *AddressGroup) ([]map[strin
 		return nil, fmt.Errorf("group %s not found", group.Name)
 	}
 
-	// Find the difference between the two groups
-	for _, ip := range ourGroup.Address {
-		if !group.Contains(ip) {
-			delGroup.Address = append(delGroup.Address, ip)
-		}
-	}
-
-	batches := len(delGroup.Address) / batchSize
-	if len(delGroup.Address)%batchSize != 0 {
-		batches++
-	}
-	// Batch the results out
-	data := make([]map[string]any, batches)
-	for i := 0; i < batches; i++ {
-		start := i * batchSize
-		end := (i + 1) * batchSize
-		if end > len(delGroup.Address) {
-			end = len(delGroup.Address)
-		}
+	return deleteData(group.DeleteData, ourGroup.Address, group.Address, size), nil
+}
 
-		data[i] = map[string]any{
-			"firewall": map[string]any{
-				"group": map[string]any{
-					"address-group": map[string]any{
-						group.Name: map[string]any{
-							"address": delGroup.Address[start:end],
-						},
-					},
-				},
-			},
-		}
-	}
-	return data, nil
+// GetCreateData returns the data needed to create the group with its
+// description. Addresses are not included, use GetSetData for those.
+func (a *AddressGroupCollection) GetCreateData(group *AddressGroup) map[string]any {
+	return SetData(group.path("description"), group.Description)
 }
 
 func (a *AddressGroupCollection) UpdateGroup(group *AddressGroup) error {
@@ -172,34 +153,50 @@ func (a *AddressGroupCollection) GetGroup(name string) (*AddressGroup, error) {
 }
 
 func NewAddressGroups(in map[string]any) (*AddressGroupCollection, error) {
-	tmp := in
+	addressGroups := AddressGroupCollection{}
 
-	path := []string{"GET", "firewall", "group", "address-group"}
-	for _, p := range path {
-		if tmp[p] == nil {
-			return nil, fmt.Errorf("path %v not found", path)
+	err := eachGroup(in, AddressGroupType, func(name, description string, values []string) {
+		addressGroups[name] = AddressGroup{
+			Name:        name,
+			Description: description,
+			Address:     values,
 		}
-		tmp = tmp[p].(map[string]any)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	addressGroups := AddressGroupCollection{}
+	return &addressGroups, nil
+}
 
-	for k, v := range tmp {
-		vmap, ok := v.(map[string]any)["address"].([]interface{})
-		if !ok {
-			vmap = make([]interface{}, 0)
-		}
-		addresSlice := make([]string, len(vmap))
-		for i, a := range vmap {
-			addresSlice[i] = a.(string)
+// eachGroup calls fn with the sorted values of every group of the given type
+// in the response from Get.
+func eachGroup(in map[string]any, t GroupType, fn func(name, description string, values []string)) error {
+	tree, err := NewConfigTree(in)
+	if err != nil {
+		return err
+	}
+
+	// A router without any groups of this type simply has no node for it
+	groups, ok := tree.Lookup("firewall", "group", string(t))
+	if !ok {
+		return nil
+	}
+
+	for k, v := range groups.All() {
+		values := []string{}
+		if n, ok := v.Lookup(t.key()); ok {
+			values = n.Values()
 		}
-		slices.Sort(addresSlice)
-		addressGroups[k] = AddressGroup{
-			Name:    k,
-			Address: addresSlice,
+		slices.Sort(values)
+
+		var description string
+		if n, ok := v.Lookup("description"); ok {
+			description, _ = n.Value()
 		}
 
+		fn(k, description, values)
 	}
 
-	return &addressGroups, nil
+	return nil
 }
@@ -0,0 +1,61 @@
+package xedgeos
+
+import "slices"
+
+// DefaultBatchSize is the number of values GetSetData and GetDeleteData put
+// in each payload. Large payloads can take EdgeOS long enough to commit that
+// the request times out.
+const DefaultBatchSize = 50
+
+// Batches splits values into consecutive batches of at most size values. A
+// size below one means DefaultBatchSize.
+func Batches(values []string, size int) [][]string {
+	if size < 1 {
+		size = DefaultBatchSize
+	}
+
+	return slices.Collect(slices.Chunk(values, size))
+}
+
+// Diff returns the values in want that are missing from ours, and those in
+// ours that are missing from want. Both slices must be sorted.
+func Diff(ours, want []string) (add, remove []string) {
+	for _, v := range want {
+		if _, has := slices.BinarySearch(ours, v); !has {
+			add = append(add, v)
+		}
+	}
+	for _, v := range ours {
+		if _, has := slices.BinarySearch(want, v); !has {
+			remove = append(remove, v)
+		}
+	}
+
+	return add, remove
+}
+
+// setData returns the values in want that are missing from ours as payloads
+// built by data, in batches of size.
+func setData(data func([]string) map[string]any, ours, want []string, size int) []map[string]any {
+	add, _ := Diff(ours, want)
+
+	return payloads(data, add, size)
+}
+
+// deleteData returns the values in ours that are missing from want as
+// payloads built by data, in batches of size.
+func deleteData(data func([]string) map[string]any, ours, want []string, size int) []map[string]any {
+	_, remove := Diff(ours, want)
+
+	return payloads(data, remove, size)
+}
+
+func payloads(data func([]string) map[string]any, values []string, size int) []map[string]any {
+	batches := Batches(values, size)
+	out := make([]map[string]any, len(batches))
+	for i, b := range batches {
+		out[i] = data(b)
+	}
+
+	return out
+}
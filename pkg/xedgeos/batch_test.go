@@ -0,0 +1,43 @@
+package xedgeos
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatches(t *testing.T) {
+	asrt := assert.New(t)
+
+	values := []string{"a", "b", "c", "d", "e"}
+	asrt.Equal([][]string{{"a", "b"}, {"c", "d"}, {"e"}}, Batches(values, 2))
+	asrt.Equal([][]string{values}, Batches(values, 0))
+	asrt.Empty(Batches(nil, 2))
+
+	add, remove := Diff([]string{"a", "b", "c"}, []string{"b", "c", "d"})
+	asrt.Equal([]string{"d"}, add)
+	asrt.Equal([]string{"a"}, remove)
+}
+
+func TestGetSetBatches(t *testing.T) {
+	asrt := assert.New(t)
+
+	ag := AddressGroupCollection{"CROWDSEC": {Name: "CROWDSEC"}}
+	group, err := ag.GetGroup("CROWDSEC")
+	asrt.NoError(err)
+	for i := range 120 {
+		group.Add(fmt.Sprintf("198.51.100.%d", i))
+	}
+
+	data, err := ag.GetSetData(group)
+	asrt.NoError(err)
+	asrt.Len(data, 3)
+	data, err = ag.GetSetBatches(group, 100)
+	asrt.NoError(err)
+	asrt.Len(data, 2)
+
+	bs, _ := json.Marshal(group.DeleteData([]string{"198.51.100.1"}))
+	asrt.JSONEq(`{"firewall":{"group":{"address-group":{"CROWDSEC":{"address":["198.51.100.1"]}}}}}`, string(bs))
+}
@@ -2,19 +2,23 @@ package xedgeos
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"log/slog"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
-	"os"
+	"slices"
+	"strings"
 )
 
 // Scenario is just a string type to encourage the use of internal constants.
 type Scenario string
 
-// Common feature endpoints
+// Common feature endpoints. Other wizards can be reached with LoadFeature and
+// ApplyFeature using their scenario name.
 const (
 	PortForwarding Scenario = ".Port_Forwarding"
 )
@@ -48,6 +52,32 @@ func (c *Client) Login() error {
 	}
 	defer res.Body.Close()
 
+	return checkStatus(res)
+}
+
+// StatusError is returned for HTTP error responses, such as those for an
+// expired session.
+type StatusError struct {
+	Method, Path string
+	StatusCode   int
+	Status       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Method, e.Path, e.Status)
+}
+
+// checkStatus turns HTTP error responses into a *StatusError.
+func checkStatus(res *http.Response) error {
+	if res.StatusCode >= http.StatusBadRequest {
+		return &StatusError{
+			Method:     res.Request.Method,
+			Path:       res.Request.URL.Path,
+			StatusCode: res.StatusCode,
+			Status:     res.Status,
+		}
+	}
+
 	return nil
 }
 
@@ -75,7 +105,11 @@ func (c *Client) DoFor(req *http.Request, out interface{}) error {
 	}
 
 	defer res.Body.Close()
-	return json.NewDecoder(io.TeeReader(res.Body, os.Stdout)).Decode(out)
+	if err := checkStatus(res); err != nil {
+		return err
+	}
+
+	return json.NewDecoder(res.Body).Decode(out)
 }
 
 // JSONFor is a high-level method that takes an endpoint, a post body, and a
@@ -96,6 +130,9 @@ func (c *Client) JSONFor(endpoint string, data, out interface{}) error {
 		return err
 	}
 	defer res.Body.Close()
+	if err := checkStatus(res); err != nil {
+		return err
+	}
 
 	return json.NewDecoder(res.Body).Decode(out)
 }
@@ -105,6 +142,27 @@ func (c *Client) Get() (Resp, error) {
 	return c.GetJSON("get", nil)
 }
 
+// GetPath returns only the configuration below path, e.g.
+// GetPath("firewall", "group"). The path's nodes are appended to the get
+// endpoint as URL segments, giving /api/edge/get/firewall/group.json, and the
+// response has the same shape as Get so it can be handed to the same parsers.
+//
+// Not every firmware is known to serve sub-paths. One that answers 404 gets
+// the whole configuration fetched instead, which contains the same subtree.
+func (c *Client) GetPath(path ...string) (Resp, error) {
+	elems := make([]string, len(path))
+	for i, p := range path {
+		elems[i] = url.PathEscape(p)
+	}
+
+	res, err := c.GetJSON("get/"+strings.Join(elems, "/"), nil)
+	if se := (*StatusError)(nil); errors.As(err, &se) && se.StatusCode == http.StatusNotFound {
+		return c.Get()
+	}
+
+	return res, err
+}
+
 // Feature takes an EdgeOS "Scenario" as an argument and returns a Resp
 // representing the JSON returned by the API.
 func (c *Client) Feature(s Scenario) (Resp, error) {
@@ -175,56 +233,141 @@ func NewClient(addr, username, password string) (*Client, error) {
 	}
 	return c, nil
 }
-func (c *Client) Batch(data BatchData) (Resp, error) {
-	var m map[string]interface{}
-
-	bs, _ := json.Marshal(data)
-	res, err := c.cli.Post(c.Endpoint("batch"), "application/json", bytes.NewReader(bs))
 
-	if err != nil {
-		return nil, err
+// SetTLSConfig replaces the TLS configuration used when talking to the device.
+// By default certificate verification is skipped since EdgeOS ships with a
+// self-signed certificate.
+func (c *Client) SetTLSConfig(cfg *tls.Config) {
+	c.cli.Transport.(*csrfTransport).RoundTripper = &http.Transport{
+		TLSClientConfig: cfg,
 	}
+}
 
-	defer res.Body.Close()
-	err = json.NewDecoder(res.Body).Decode(&m)
+// SetLogger sets the logger that requests and responses are written to at
+// debug level, with credentials, session cookies, CSRF tokens and the secrets
+// in config dumps redacted. By default slog.Default() is used.
+func (c *Client) SetLogger(l *slog.Logger) {
+	c.cli.Transport.(*csrfTransport).Logger = l
+}
 
-	return m, err
+// Batch sends deletes and sets to the EdgeOS API in one commit. A change the
+// router rejects is returned as a *ChangeError.
+func (c *Client) Batch(data BatchData) (Resp, error) {
+	return c.change("batch", data)
 }
 
-// Delete takes a map of data and sends it to the EdgeOS API
+// Delete takes a map of data and sends it to the EdgeOS API. A change the
+// router rejects is returned as a *ChangeError.
 func (c *Client) Delete(data any) (Resp, error) {
+	return c.change("delete", data)
+}
+
+// Set takes a map of data and sends it to the EdgeOS API. A change the
+// router rejects is returned as a *ChangeError.
+func (c *Client) Set(data any) (Resp, error) {
+	return c.change("set", data)
+}
+
+func (c *Client) change(endpoint string, data any) (Resp, error) {
 	var m map[string]interface{}
 
 	bs, _ := json.Marshal(data)
-	res, err := c.cli.Post(c.Endpoint("delete"), "application/json", bytes.NewReader(bs))
+	res, err := c.cli.Post(c.Endpoint(endpoint), "application/json", bytes.NewReader(bs))
 	if err != nil {
 		return nil, err
 	}
 
 	defer res.Body.Close()
-	err = json.NewDecoder(res.Body).Decode(&m)
+	if err := checkStatus(res); err != nil {
+		return nil, err
+	}
+	if err := json.NewDecoder(res.Body).Decode(&m); err != nil {
+		return nil, err
+	}
 
-	return m, err
+	return m, checkChange(endpoint, m)
 }
 
-// Set takes a map of data and sends it to the EdgeOS API
-func (c *Client) Set(data any) (Resp, error) {
-	var m map[string]interface{}
+// ChangeError is returned when the router answers a set, delete or batch
+// request but reports that the change, or committing or saving it, failed.
+type ChangeError struct {
+	Endpoint string
+	// Op is the failed step, such as SET or COMMIT. It is empty when only
+	// the overall result says the request failed.
+	Op     string
+	Detail string
+}
 
-	bs, _ := json.Marshal(data)
-	res, err := c.cli.Post(c.Endpoint("set"), "application/json", bytes.NewReader(bs))
+func (e *ChangeError) Error() string {
+	msg := e.Endpoint + ": router rejected the change"
+	if e.Op != "" {
+		msg += " (" + e.Op + ")"
+	}
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
 
-	if err != nil {
-		return nil, err
+	return msg
+}
+
+// checkChange looks for failures the router reports in the body of a change
+// response. Each step has its own success and failure flags and an error
+// that maps config paths to messages; the top-level success flag covers the
+// whole request.
+func checkChange(endpoint string, m Resp) error {
+	for _, op := range []string{"DELETE", "SET", "COMMIT", "SAVE"} {
+		step, ok := m[op].(map[string]any)
+		if !ok {
+			continue
+		}
+		if flag(step["failure"]) || (step["success"] != nil && !flag(step["success"])) {
+			return &ChangeError{Endpoint: endpoint, Op: op, Detail: changeDetail(step["error"])}
+		}
+	}
+	if v, ok := m["success"]; ok && !flag(v) {
+		return &ChangeError{Endpoint: endpoint, Detail: changeDetail(m["error"])}
 	}
 
-	defer res.Body.Close()
-	err = json.NewDecoder(res.Body).Decode(&m)
+	return nil
+}
 
-	return m, err
+// flag reads the API's booleans, which come as "1"/"0" strings or as JSON
+// booleans depending on the endpoint.
+func flag(v any) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "1" || v == "true"
+	case float64:
+		return v != 0
+	}
+
+	return false
+}
+
+func changeDetail(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]any:
+		msgs := make([]string, 0, len(v))
+		for path, msg := range v {
+			msgs = append(msgs, strings.TrimSpace(fmt.Sprintf("%s %v", path, msg)))
+		}
+		slices.Sort(msgs)
+
+		return strings.Join(msgs, "; ")
+	}
+
+	return fmt.Sprint(v)
 }
 
+// BatchData is the body of a batch request. Deletes are applied before sets
+// and both are committed together.
 type BatchData struct {
-	Set    map[string]interface{} `json:"SET"`
-	Delete map[string]interface{} `json:"DELETE"`
+	Set    map[string]interface{} `json:"SET,omitempty"`
+	Delete map[string]interface{} `json:"DELETE,omitempty"`
 }
@@ -0,0 +1,105 @@
+package xedgeos
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPath(t *testing.T) {
+	asrt := assert.New(t)
+
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		fmt.Fprint(w, `{"GET":{"firewall":{"group":{}}},"success":true}`)
+	}))
+	defer srv.Close()
+	c, err := NewClient(srv.URL, "ubnt", "ubnt")
+	asrt.NoError(err)
+
+	res, err := c.GetPath("firewall", "group", "address-group", "A/B")
+	asrt.NoError(err)
+	asrt.Contains(res, "GET")
+	asrt.Equal([]string{"/api/edge/get/firewall/group/address-group/A%2FB.json"}, paths)
+}
+
+func TestGetPathFallback(t *testing.T) {
+	asrt := assert.New(t)
+
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		if r.URL.Path == "/api/edge/get/system.json" {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		if r.URL.Path != "/api/edge/get.json" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"GET":{"firewall":{"group":{}}},"success":true}`)
+	}))
+	defer srv.Close()
+	c, err := NewClient(srv.URL, "ubnt", "ubnt")
+	asrt.NoError(err)
+
+	res, err := c.GetPath("firewall", "group")
+	asrt.NoError(err)
+	asrt.Contains(res, "GET")
+	asrt.Equal([]string{"/api/edge/get/firewall/group.json", "/api/edge/get.json"}, paths)
+
+	// Other errors are not retried.
+	paths = nil
+	_, err = c.GetPath("system")
+	var se *StatusError
+	asrt.ErrorAs(err, &se)
+	asrt.Equal(http.StatusInternalServerError, se.StatusCode)
+	asrt.Equal([]string{"/api/edge/get/system.json"}, paths)
+}
+
+func TestChangeFailure(t *testing.T) {
+	asrt := assert.New(t)
+
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, body)
+	}))
+	defer srv.Close()
+	c, err := NewClient(srv.URL, "ubnt", "ubnt")
+	asrt.NoError(err)
+
+	for _, tc := range []struct {
+		body, op, detail string
+	}{
+		{body: `{"SET":{"failure":"0","success":"1"},"COMMIT":{"error":null,"failure":"0","success":"1"},"SAVE":{"success":"1"},"success":"1"}`},
+		{body: `{"SET":{"success":"1"},"success":true}`},
+		{
+			body:   `{"SET":{"error":{"firewall group address-group X address 192.0.2.300":"not a valid address"},"failure":"1","success":"0"},"success":"0"}`,
+			op:     "SET",
+			detail: "firewall group address-group X address 192.0.2.300 not a valid address",
+		},
+		{
+			body:   `{"SET":{"failure":"0","success":"1"},"COMMIT":{"error":"commit failed","failure":"1","success":"0"},"success":"0"}`,
+			op:     "COMMIT",
+			detail: "commit failed",
+		},
+		{body: `{"success":false,"error":"bad request"}`, detail: "bad request"},
+	} {
+		body = tc.body
+		_, err := c.Set(map[string]any{})
+		if tc.op == "" && tc.detail == "" {
+			asrt.NoError(err, tc.body)
+			continue
+		}
+		var ce *ChangeError
+		if asrt.ErrorAs(err, &ce, tc.body) {
+			asrt.Equal("set", ce.Endpoint)
+			asrt.Equal(tc.op, ce.Op)
+			asrt.Equal(tc.detail, ce.Detail)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package xedgeos
+
+import (
+	"encoding/json"
+	"fmt"
+	"iter"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// NodeKind distinguishes the shapes a value in the EdgeOS configuration can
+// take.
+type NodeKind int
+
+const (
+	// KindNode is a node with named children, e.g. `firewall group`.
+	KindNode NodeKind = iota
+	// KindLeaf is a single valued leaf, e.g. `firewall all-ping`.
+	KindLeaf
+	// KindMulti is a leaf that can hold multiple values, e.g.
+	// `firewall group address-group FOO address`.
+	KindMulti
+	// KindValueless is a leaf that is present without a value, e.g.
+	// `firewall name WAN_IN enable-default-log`.
+	KindValueless
+	// KindUnknown is anything the API is not expected to return.
+	KindUnknown
+)
+
+// ParsePath splits a space separated configuration path such as
+// "firewall group address-group FOO" into its elements.
+func ParsePath(s string) []string {
+	return strings.Fields(s)
+}
+
+// ConfigTree wraps the configuration returned by the get endpoint and allows
+// it to be queried by path without walking untyped maps by hand.
+type ConfigTree struct {
+	root Node
+}
+
+// NewConfigTree returns a ConfigTree for the response from Get.
+func NewConfigTree(in map[string]any) (*ConfigTree, error) {
+	get, ok := in["GET"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("path %v not found", []string{"GET"})
+	}
+
+	return &ConfigTree{root: Node{value: get}}, nil
+}
+
+// Root returns the top level node of the configuration.
+func (t *ConfigTree) Root() Node {
+	return t.root
+}
+
+// Lookup returns the node at path, or false if it doesn't exist.
+func (t *ConfigTree) Lookup(path ...string) (Node, bool) {
+	return t.root.Lookup(path...)
+}
+
+// Node is a single value in a ConfigTree.
+type Node struct {
+	value any
+}
+
+// Kind returns the shape of the node.
+func (n Node) Kind() NodeKind {
+	switch n.value.(type) {
+	case map[string]any:
+		return KindNode
+	case string:
+		return KindLeaf
+	case []any:
+		return KindMulti
+	case nil:
+		return KindValueless
+	default:
+		return KindUnknown
+	}
+}
+
+// Lookup returns the descendant of n at path, or false if it doesn't exist.
+func (n Node) Lookup(path ...string) (Node, bool) {
+	cur := n
+	for _, p := range path {
+		m, ok := cur.value.(map[string]any)
+		if !ok {
+			return Node{}, false
+		}
+		v, ok := m[p]
+		if !ok {
+			return Node{}, false
+		}
+		cur = Node{value: v}
+	}
+
+	return cur, true
+}
+
+// Value returns the value of a single valued leaf.
+func (n Node) Value() (string, bool) {
+	s, ok := n.value.(string)
+	return s, ok
+}
+
+// Values returns the values of a leaf. Single valued leaves are returned as a
+// one element slice so callers don't need to care how many values EdgeOS
+// happened to return.
+func (n Node) Values() []string {
+	switch v := n.value.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+// Children returns the sorted names of the children of a node.
+func (n Node) Children() []string {
+	m, ok := n.value.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	return slices.Sorted(maps.Keys(m))
+}
+
+// All iterates over the children of a node in name order.
+func (n Node) All() iter.Seq2[string, Node] {
+	return func(yield func(string, Node) bool) {
+		m, _ := n.value.(map[string]any)
+		for _, k := range n.Children() {
+			if !yield(k, Node{value: m[k]}) {
+				return
+			}
+		}
+	}
+}
+
+// Decode unmarshals the node into out, which should be a pointer to a struct
+// with json tags matching the EdgeOS configuration names.
+func (n Node) Decode(out any) error {
+	bs, err := json.Marshal(n.value)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(bs, out)
+}
+
+// SetData returns the data needed to set path to value with the set endpoint.
+// value is typically a string, a slice of strings for multi-valued leaves, or
+// a map for whole nodes.
+func SetData(path []string, value any) map[string]any {
+	return pathData(path, value)
+}
+
+// DeleteData returns the data needed to delete path with the delete endpoint.
+// When values are given only those values are removed from a multi-valued
+// leaf, otherwise the whole node is removed.
+func DeleteData(path []string, values ...string) map[string]any {
+	if len(values) == 0 {
+		return pathData(path, nil)
+	}
+
+	return pathData(path, values)
+}
+
+// MergeData deep merges payloads built by SetData or DeleteData so they can be
+// sent in a single request. Later payloads win where they set the same leaf.
+func MergeData(data ...map[string]any) map[string]any {
+	out := map[string]any{}
+	for _, d := range data {
+		mergeData(out, d)
+	}
+
+	return out
+}
+
+func mergeData(dst, src map[string]any) {
+	for k, v := range src {
+		sm, ok := v.(map[string]any)
+		if !ok {
+			dst[k] = v
+			continue
+		}
+		dm, ok := dst[k].(map[string]any)
+		if !ok {
+			dm = map[string]any{}
+			dst[k] = dm
+		}
+		mergeData(dm, sm)
+	}
+}
+
+func pathData(path []string, value any) map[string]any {
+	var out any = value
+	for i := len(path) - 1; i >= 0; i-- {
+		out = map[string]any{path[i]: out}
+	}
+
+	m, _ := out.(map[string]any)
+
+	return m
+}
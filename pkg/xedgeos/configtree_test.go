@@ -0,0 +1,112 @@
+package xedgeos
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testConfigJSON = `{"success":true,"GET":{"firewall":{"all-ping":"enable","group":{"address-group":{"CROWDSEC":{"description":"CrowdSec bouncer","address":["1.1.1.1","2.2.2.2"]},"SINGLE":{"address":"3.3.3.3"},"EMPTY":{"description":"nothing here"}}},"name":{"WAN_IN":{"default-action":"drop","enable-default-log":null}}}}}`
+
+func testTree(t *testing.T) *ConfigTree {
+	var res Resp
+	assert.NoError(t, json.Unmarshal([]byte(testConfigJSON), &res))
+
+	tree, err := NewConfigTree(res)
+	assert.NoError(t, err)
+
+	return tree
+}
+
+func TestConfigTreeLookup(t *testing.T) {
+	asrt := assert.New(t)
+	tree := testTree(t)
+
+	n, ok := tree.Lookup(ParsePath("firewall group address-group CROWDSEC address")...)
+	asrt.True(ok)
+	asrt.Equal(KindMulti, n.Kind())
+	asrt.Equal([]string{"1.1.1.1", "2.2.2.2"}, n.Values())
+
+	n, ok = tree.Lookup(ParsePath("firewall group address-group SINGLE address")...)
+	asrt.True(ok)
+	asrt.Equal(KindLeaf, n.Kind())
+	asrt.Equal([]string{"3.3.3.3"}, n.Values())
+
+	n, ok = tree.Lookup(ParsePath("firewall name WAN_IN enable-default-log")...)
+	asrt.True(ok)
+	asrt.Equal(KindValueless, n.Kind())
+
+	n, ok = tree.Lookup("firewall", "all-ping")
+	asrt.True(ok)
+	v, ok := n.Value()
+	asrt.True(ok)
+	asrt.Equal("enable", v)
+
+	// Walking through a leaf is a miss rather than a panic
+	_, ok = tree.Lookup("firewall", "all-ping", "nope")
+	asrt.False(ok)
+	_, ok = tree.Lookup("service")
+	asrt.False(ok)
+}
+
+func TestConfigTreeIterate(t *testing.T) {
+	asrt := assert.New(t)
+	tree := testTree(t)
+
+	groups, ok := tree.Lookup("firewall", "group", "address-group")
+	asrt.True(ok)
+	asrt.Equal(KindNode, groups.Kind())
+	asrt.Equal([]string{"CROWDSEC", "EMPTY", "SINGLE"}, groups.Children())
+
+	var names []string
+	for k := range groups.All() {
+		names = append(names, k)
+	}
+	asrt.Equal(groups.Children(), names)
+
+	_, err := NewConfigTree(Resp{"success": false})
+	asrt.Error(err)
+}
+
+func TestPathData(t *testing.T) {
+	asrt := assert.New(t)
+	path := ParsePath("firewall group address-group FOO address")
+
+	bs, err := json.Marshal(SetData(path, []string{"1.1.1.1"}))
+	asrt.NoError(err)
+	asrt.JSONEq(`{"firewall":{"group":{"address-group":{"FOO":{"address":["1.1.1.1"]}}}}}`, string(bs))
+
+	bs, err = json.Marshal(DeleteData(path, "1.1.1.1"))
+	asrt.NoError(err)
+	asrt.JSONEq(`{"firewall":{"group":{"address-group":{"FOO":{"address":["1.1.1.1"]}}}}}`, string(bs))
+
+	bs, err = json.Marshal(DeleteData(path))
+	asrt.NoError(err)
+	asrt.JSONEq(`{"firewall":{"group":{"address-group":{"FOO":{"address":null}}}}}`, string(bs))
+}
+
+func TestMergeData(t *testing.T) {
+	asrt := assert.New(t)
+
+	bs, err := json.Marshal(MergeData(
+		SetData(ParsePath("firewall name WAN_IN rule 1 source group address-group"), "FOO"),
+		SetData(ParsePath("firewall name WAN_IN rule 2 destination group address-group"), "FOO"),
+	))
+	asrt.NoError(err)
+	asrt.JSONEq(`{"firewall":{"name":{"WAN_IN":{"rule":{
+		"1":{"source":{"group":{"address-group":"FOO"}}},
+		"2":{"destination":{"group":{"address-group":"FOO"}}}}}}}}`, string(bs))
+}
+
+func TestAddressGroupsSingleValue(t *testing.T) {
+	asrt := assert.New(t)
+	var res Resp
+	asrt.NoError(json.Unmarshal([]byte(testConfigJSON), &res))
+
+	ag, err := NewAddressGroups(res)
+	asrt.NoError(err)
+	asrt.Equal([]string{"3.3.3.3"}, (*ag)["SINGLE"].Address)
+	asrt.Equal("CrowdSec bouncer", (*ag)["CROWDSEC"].Description)
+	asrt.Empty((*ag)["EMPTY"].Address)
+}
@@ -1,21 +1,47 @@
 package xedgeos
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
-	"fmt"
+	"encoding/json"
+	"io"
+	"log/slog"
 	"net/http"
-	"os"
+	"net/url"
+	"strings"
 )
 
-// type rtf func(*http.Request) (*http.Response, error)
+const redacted = "REDACTED"
+
+// sensitiveHeaders carry the session cookie and CSRF token and are never
+// written to the debug log.
+var sensitiveHeaders = []string{"Cookie", "Set-Cookie", "X-Csrf-Token", "Authorization"}
+
+// sensitiveKeys are config nodes holding secrets, such as login and PPPoE
+// passwords, IPsec pre-shared secrets, RADIUS secrets, private keys and SNMP
+// communities. Config nodes whose name contains one of them are masked
+// whole in the debug log.
+var sensitiveKeys = []string{"password", "secret", "private-key", "community"}
+
 type csrfTransport struct {
 	Referrer string
 	CSRF     string
-	Debug    bool
+	// Logger receives a dump of every request and response at debug
+	// level. Nil means slog.Default().
+	Logger *slog.Logger
 
 	RoundTripper http.RoundTripper
 }
 
+func (r *csrfTransport) logger() *slog.Logger {
+	if r.Logger == nil {
+		return slog.Default()
+	}
+
+	return r.Logger
+}
+
 func (r *csrfTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	if r.RoundTripper == nil {
 		r.RoundTripper = &http.Transport{
@@ -29,35 +55,152 @@ func (r *csrfTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 		req.Header.Set("X-Requested-With", "XMLHttpRequest")
 	}
 
-	// req.Header.Set("Origin", "https://www.hackerrank.com")
 	req.Header.Set("Referer", r.Referrer+"/")
 
 	if r.CSRF != "" {
 		req.Header.Set("X-CSRF-Token", r.CSRF)
 	}
 
+	log := r.logger()
+	debug := log.Enabled(req.Context(), slog.LevelDebug)
+	if debug {
+		logRequest(req.Context(), log, req)
+	}
+
 	res, err := r.RoundTripper.RoundTrip(req)
 	if err != nil {
 		return nil, err
 	}
 
-	if res.Cookies() != nil {
-		for _, cookie := range res.Cookies() {
-			if cookie.Name == "X-CSRF-TOKEN" {
-				r.CSRF = cookie.Value
+	for _, cookie := range res.Cookies() {
+		if cookie.Name == "X-CSRF-TOKEN" {
+			r.CSRF = cookie.Value
+		}
+	}
+
+	if debug {
+		logResponse(req.Context(), log, res)
+	}
+
+	return res, nil
+}
+
+func logRequest(ctx context.Context, log *slog.Logger, req *http.Request) {
+	var body []byte
+	if req.GetBody != nil {
+		if rc, err := req.GetBody(); err == nil {
+			body, _ = io.ReadAll(rc)
+			rc.Close()
+		}
+	}
+
+	log.DebugContext(ctx, "http request",
+		"method", req.Method,
+		"url", req.URL.Redacted(),
+		"header", redactHeader(req.Header),
+		"body", redactBody(req.Header.Get("Content-Type"), body),
+	)
+}
+
+// logResponse logs res, reading its body and putting it back for the caller.
+func logResponse(ctx context.Context, log *slog.Logger, res *http.Response) {
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	res.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), errReader{err}))
+
+	log.DebugContext(ctx, "http response",
+		"status", res.StatusCode,
+		"header", redactHeader(res.Header),
+		"body", redactBody(res.Header.Get("Content-Type"), body),
+	)
+}
+
+// errReader returns err, or io.EOF when err is nil, so a failed read of the
+// original body still reaches the caller.
+type errReader struct{ err error }
+
+func (e errReader) Read([]byte) (int, error) {
+	if e.err == nil {
+		return 0, io.EOF
+	}
+
+	return 0, e.err
+}
+
+// redactHeader returns a copy of h with session cookies and CSRF tokens
+// masked.
+func redactHeader(h http.Header) http.Header {
+	out := h.Clone()
+	for _, k := range sensitiveHeaders {
+		if _, ok := out[k]; ok {
+			out[k] = []string{redacted}
+		}
+	}
+
+	return out
+}
+
+// redactBody masks the password in a login form and the secrets in JSON
+// bodies, which can carry the whole router config. Other bodies are returned
+// as is.
+func redactBody(contentType string, body []byte) string {
+	if !strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		return redactJSON(body)
+	}
+	v, err := url.ParseQuery(string(body))
+	if err != nil {
+		return redacted
+	}
+	if v.Has("password") {
+		v.Set("password", redacted)
+	}
+
+	return v.Encode()
+}
+
+// redactJSON masks the values of sensitiveKeys anywhere in body. Bodies that
+// are not JSON are returned as is.
+func redactJSON(body []byte) string {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return string(body)
+	}
+	out, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return redacted
+	}
+
+	return string(out)
+}
+
+func redactValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			if sensitiveKey(k) {
+				v[k] = redacted
+			} else {
+				v[k] = redactValue(child)
 			}
 		}
+	case []any:
+		for i, child := range v {
+			v[i] = redactValue(child)
+		}
 	}
 
-	if r.Debug {
-		fmt.Println("Req")
-		req.Write(os.Stdout)
-		fmt.Println()
+	return v
+}
 
-		fmt.Println("Res")
-		res.Write(os.Stdout)
-		fmt.Println()
+func sensitiveKey(k string) bool {
+	k = strings.ToLower(k)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(k, s) {
+			return true
+		}
 	}
 
-	return res, err
+	return false
 }
@@ -0,0 +1,55 @@
+package xedgeos
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugRedaction(t *testing.T) {
+	asrt := assert.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			http.SetCookie(w, &http.Cookie{Name: "PHPSESSID", Value: "session-secret"})
+			http.SetCookie(w, &http.Cookie{Name: "X-CSRF-TOKEN", Value: "csrf-secret"})
+			return
+		}
+		fmt.Fprint(w, `{"GET":{"firewall":{},"system":{"login":{"user":{"ubnt":{"authentication":{"encrypted-password":"hash-secret","plaintext-password":""}}}}},"vpn":{"ipsec":{"site-to-site":{"peer":{"203.0.113.1":{"authentication":{"pre-shared-secret":"psk-secret"}}}}}},"service":{"snmp":{"community":{"community-secret":{"authorization":"ro"}}}}},"success":true}`)
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	c, err := NewClient(srv.URL, "ubnt", "password-secret")
+	asrt.NoError(err)
+	c.SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	asrt.NoError(c.Login())
+	res, err := c.Get()
+	asrt.NoError(err)
+	asrt.Equal(true, res["success"])
+
+	out := buf.String()
+	asrt.Contains(out, "username=ubnt")
+	asrt.Contains(out, `firewall`)
+	asrt.Contains(out, redacted)
+	asrt.NotContains(out, "password-secret")
+	asrt.NotContains(out, "session-secret")
+	asrt.NotContains(out, "csrf-secret")
+	// Secrets in a config dump are masked too.
+	asrt.Contains(out, "encrypted-password")
+	asrt.NotContains(out, "hash-secret")
+	asrt.NotContains(out, "psk-secret")
+	asrt.NotContains(out, "community-secret")
+
+	// Nothing is dumped above debug level.
+	buf.Reset()
+	c.SetLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+	_, err = c.Get()
+	asrt.NoError(err)
+	asrt.Empty(buf.String())
+}
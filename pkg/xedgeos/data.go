@@ -0,0 +1,115 @@
+package xedgeos
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+)
+
+// DataResponseOf is the response from the read-only data endpoint, with the
+// output decoded into T.
+type DataResponseOf[T any] struct {
+	Success string `json:"success"`
+	Error   string `json:"error"`
+	Output  T      `json:"output"`
+}
+
+// Data fetches the named dataset (e.g. "dhcp_leases") from the data endpoint.
+func Data[T any](c *Client, name string) (*T, error) {
+	req, err := http.NewRequest(http.MethodGet, c.Endpoint("data")+"?data="+url.QueryEscape(name), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &DataResponseOf[T]{}
+	if err := c.DoFor(req, res); err != nil {
+		return nil, err
+	}
+	if res.Success != "1" {
+		return nil, fmt.Errorf("fetching %s: %s", name, res.Error)
+	}
+
+	return &res.Output, nil
+}
+
+// DHCPLease is a single lease handed out by the router's DHCP server.
+type DHCPLease struct {
+	Expiration string `json:"expiration"`
+	Pool       string `json:"pool"`
+	MAC        string `json:"mac"`
+	Hostname   string `json:"client-hostname"`
+}
+
+// DHCPLeases holds the active leases keyed by pool and then address.
+type DHCPLeases struct {
+	Pools map[string]map[string]DHCPLease `json:"dhcp-server-leases"`
+}
+
+// Addresses returns every leased address.
+func (d DHCPLeases) Addresses() []string {
+	var out []string
+	for _, leases := range d.Pools {
+		for addr := range leases {
+			out = append(out, addr)
+		}
+	}
+
+	return out
+}
+
+func (c *Client) DHCPLeases() (*DHCPLeases, error) {
+	return Data[DHCPLeases](c, "dhcp_leases")
+}
+
+// SystemInfo describes the router hardware and firmware.
+type SystemInfo struct {
+	Model   string `json:"model"`
+	HWRev   string `json:"hw_rev"`
+	Version string `json:"sw_ver"`
+}
+
+func (c *Client) SystemInfo() (*SystemInfo, error) {
+	return Data[SystemInfo](c, "sys_info")
+}
+
+// DefaultRoute is the router's IPv4 default route.
+type DefaultRoute struct {
+	Gateway   string `json:"gateway"`
+	Interface string `json:"interface"`
+}
+
+func (c *Client) DefaultRoute() (*DefaultRoute, error) {
+	return Data[DefaultRoute](c, "default_route")
+}
+
+// Interface is the state of a single network interface.
+type Interface struct {
+	Up        string   `json:"up"`
+	MAC       string   `json:"mac"`
+	MTU       string   `json:"mtu"`
+	Addresses []string `json:"addresses"`
+}
+
+// Prefixes returns the interface addresses as prefixes, skipping anything
+// that doesn't parse.
+func (i Interface) Prefixes() []netip.Prefix {
+	var out []netip.Prefix
+	for _, a := range i.Addresses {
+		if p, err := netip.ParsePrefix(a); err == nil {
+			out = append(out, p.Masked())
+		}
+	}
+
+	return out
+}
+
+// Interfaces returns the router's interfaces keyed by name.
+func (c *Client) Interfaces() (map[string]Interface, error) {
+	ifaces, err := Data[map[string]Interface](c, "interfaces")
+	if err != nil {
+		return nil, err
+	}
+
+	return *ifaces, nil
+}
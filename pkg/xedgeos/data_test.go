@@ -0,0 +1,61 @@
+package xedgeos
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testData = map[string]string{
+	"dhcp_leases":   `{"dhcp-server-leases":{"LAN":{"192.168.16.101":{"expiration":"2026/10/20 10:00:00","pool":"LAN","mac":"00:11:22:33:44:55","client-hostname":"laptop"}},"GUEST":{"10.10.0.5":{"expiration":"2026/10/20 11:00:00","pool":"GUEST","mac":"00:11:22:33:44:66","client-hostname":""}}}}`,
+	"sys_info":      `{"model":"ER-X","hw_rev":"1.0","sw_ver":"EdgeRouter.ER-e50.v2.0.9-hotfix.7.5622762.230615.1131"}`,
+	"default_route": `{"gateway":"203.0.113.1","interface":"eth0"}`,
+	"interfaces":    `{"eth0":{"up":"true","mac":"00:aa:bb:cc:dd:00","mtu":"1500","addresses":["203.0.113.10/24"]},"eth1":{"up":"true","mac":"00:aa:bb:cc:dd:01","mtu":"1500","addresses":["192.168.16.1/22","fe80::1/64"]}}`,
+}
+
+func dataServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/edge/data.json", r.URL.Path)
+		out, ok := testData[r.URL.Query().Get("data")]
+		if !ok {
+			fmt.Fprint(w, `{"success":"0","error":"unknown data"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"success":"1","output":%s}`, out)
+	}))
+}
+
+func TestData(t *testing.T) {
+	asrt := assert.New(t)
+	srv := dataServer(t)
+	defer srv.Close()
+	c, err := NewClient(srv.URL, "ubnt", "ubnt")
+	asrt.NoError(err)
+
+	leases, err := c.DHCPLeases()
+	asrt.NoError(err)
+	asrt.ElementsMatch([]string{"192.168.16.101", "10.10.0.5"}, leases.Addresses())
+	asrt.Equal("laptop", leases.Pools["LAN"]["192.168.16.101"].Hostname)
+
+	info, err := c.SystemInfo()
+	asrt.NoError(err)
+	asrt.Equal("ER-X", info.Model)
+
+	route, err := c.DefaultRoute()
+	asrt.NoError(err)
+	asrt.Equal("eth0", route.Interface)
+
+	ifaces, err := c.Interfaces()
+	asrt.NoError(err)
+	asrt.Equal([]netip.Prefix{
+		netip.MustParsePrefix("192.168.16.0/22"),
+		netip.MustParsePrefix("fe80::/64"),
+	}, ifaces["eth1"].Prefixes())
+
+	_, err = Data[map[string]any](c, "bogus")
+	asrt.Error(err)
+}
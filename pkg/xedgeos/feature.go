@@ -0,0 +1,50 @@
+package xedgeos
+
+import "fmt"
+
+// Response is a struct (intended for embedding) that represents response
+// metadata returned by the EdgeOS API.
+type Response struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error"`
+}
+
+// FeatureResponseOf encapsulates the Response metadata and the Feature, with
+// the feature data decoded into T.
+type FeatureResponseOf[T any] struct {
+	Response
+	Feature FeatureOf[T] `json:"FEATURE"`
+}
+
+// FeatureOf is the body of a feature endpoint response.
+type FeatureOf[T any] struct {
+	Data       T
+	Definition interface{}
+
+	Deletable string
+	Success   string
+}
+
+// LoadFeature loads the scenario's data into a T. The request is the
+// feature endpoint's "load" action, the same call the EdgeOS UI makes when a
+// wizard is opened.
+func LoadFeature[T any](c *Client, s Scenario) (*FeatureResponseOf[T], error) {
+	res := &FeatureResponseOf[T]{}
+	err := c.FeatureFor(s, res)
+	return res, err
+}
+
+// ApplyFeature applies data to the scenario with the feature endpoint's
+// "apply" action and returns the router's view of it afterwards. A response
+// without success is returned as an error.
+func ApplyFeature[T any](c *Client, s Scenario, data T) (*FeatureResponseOf[T], error) {
+	res := &FeatureResponseOf[T]{}
+	if err := c.SetFeatureFor(s, data, res); err != nil {
+		return res, err
+	}
+	if !res.Success {
+		return res, fmt.Errorf("applying %s: %s", s, res.Error)
+	}
+
+	return res, nil
+}
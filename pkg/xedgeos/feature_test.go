@@ -0,0 +1,70 @@
+package xedgeos
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testScenario stands in for any wizard without a typed implementation.
+const testScenario Scenario = ".Test"
+
+type testFeature struct {
+	Enable string `json:"enable"`
+}
+
+// featureServer serves data for "load" and echoes the data back for
+// "apply", failing the scenario named fail.
+func featureServer(t *testing.T, data string, fail Scenario) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Data struct {
+				Action   string          `json:"action"`
+				Scenario Scenario        `json:"scenario"`
+				Apply    json.RawMessage `json:"apply"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		if req.Data.Scenario == fail {
+			fmt.Fprint(w, `{"success":false,"error":"invalid"}`)
+			return
+		}
+		out := req.Data.Apply
+		if req.Data.Action == "load" {
+			out = json.RawMessage(data)
+		}
+		fmt.Fprintf(w, `{"success":true,"FEATURE":{"data":%s,"success":"1"}}`, out)
+	}))
+}
+
+func TestLoadApplyFeature(t *testing.T) {
+	asrt := assert.New(t)
+	srv := featureServer(t, `{"enable":"true"}`, "")
+	defer srv.Close()
+	c, err := NewClient(srv.URL, "ubnt", "ubnt")
+	asrt.NoError(err)
+
+	res, err := LoadFeature[testFeature](c, testScenario)
+	asrt.NoError(err)
+	asrt.Equal("true", res.Feature.Data.Enable)
+
+	res, err = ApplyFeature(c, testScenario, testFeature{Enable: "false"})
+	asrt.NoError(err)
+	asrt.Equal("false", res.Feature.Data.Enable)
+}
+
+func TestApplyFeatureFailure(t *testing.T) {
+	asrt := assert.New(t)
+	srv := featureServer(t, `{}`, testScenario)
+	defer srv.Close()
+	c, err := NewClient(srv.URL, "ubnt", "ubnt")
+	asrt.NoError(err)
+
+	_, err = ApplyFeature(c, testScenario, testFeature{})
+	asrt.ErrorContains(err, "invalid")
+}
@@ -0,0 +1,130 @@
+package xedgeos
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// RulesetCollection holds the `firewall name` rulesets keyed by name.
+type RulesetCollection map[string]Ruleset
+
+// Ruleset is a single `firewall name <ruleset>` node.
+type Ruleset struct {
+	Name          string       `json:"-"`
+	DefaultAction string       `json:"default-action,omitempty"`
+	Description   string       `json:"description,omitempty"`
+	Rules         map[int]Rule `json:"rule,omitempty"`
+}
+
+// Rule is a single `firewall name <ruleset> rule <n>` node.
+type Rule struct {
+	Action      string      `json:"action,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Log         string      `json:"log,omitempty"`
+	Protocol    string      `json:"protocol,omitempty"`
+	State       *RuleState  `json:"state,omitempty"`
+	Source      *RuleTarget `json:"source,omitempty"`
+	Destination *RuleTarget `json:"destination,omitempty"`
+}
+
+// RuleState matches on connection tracking state. Each field is "enable" or
+// "disable".
+type RuleState struct {
+	Established string `json:"established,omitempty"`
+	Invalid     string `json:"invalid,omitempty"`
+	New         string `json:"new,omitempty"`
+	Related     string `json:"related,omitempty"`
+}
+
+// RuleTarget is the source or destination match of a rule.
+type RuleTarget struct {
+	Address string     `json:"address,omitempty"`
+	Port    string     `json:"port,omitempty"`
+	Group   *RuleGroup `json:"group,omitempty"`
+}
+
+// RuleGroup references firewall groups from a rule source or destination.
+type RuleGroup struct {
+	AddressGroup string `json:"address-group,omitempty"`
+	NetworkGroup string `json:"network-group,omitempty"`
+	PortGroup    string `json:"port-group,omitempty"`
+}
+
+// DropRule returns a rule that drops traffic sourced from the group.
+func DropRule(source RuleGroup, description string) Rule {
+	return Rule{
+		Action:      "drop",
+		Description: description,
+		Source: &RuleTarget{
+			Group: &source,
+		},
+	}
+}
+
+// DropsSource reports whether the rule drops traffic sourced from the group.
+func (r Rule) DropsSource(source RuleGroup) bool {
+	return r.Action == "drop" &&
+		r.Source != nil &&
+		r.Source.Group != nil &&
+		*r.Source.Group == source
+}
+
+// GetSetData returns the data needed to set the rule as number n of the
+// ruleset.
+func (r Rule) GetSetData(ruleset string, n int) (map[string]any, error) {
+	bs, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	var rule map[string]any
+	if err := json.Unmarshal(bs, &rule); err != nil {
+		return nil, err
+	}
+
+	return SetData(rulePath(ruleset, n), rule), nil
+}
+
+// GetRuleDeleteData returns the data needed to delete rule n of the ruleset.
+func GetRuleDeleteData(ruleset string, n int) map[string]any {
+	return DeleteData(rulePath(ruleset, n))
+}
+
+func rulePath(ruleset string, n int) []string {
+	return []string{"firewall", "name", ruleset, "rule", strconv.Itoa(n)}
+}
+
+func (a *RulesetCollection) GetRuleset(name string) (*Ruleset, error) {
+	tmp, ok := (*a)[name]
+	if !ok {
+		return nil, fmt.Errorf("ruleset %s not found", name)
+	}
+
+	tmp.Name = name
+
+	return &tmp, nil
+}
+
+// NewRulesets parses the `firewall name` rulesets from the response from Get.
+func NewRulesets(in map[string]any) (*RulesetCollection, error) {
+	tree, err := NewConfigTree(in)
+	if err != nil {
+		return nil, err
+	}
+
+	rulesets := RulesetCollection{}
+
+	names, ok := tree.Lookup("firewall", "name")
+	if !ok {
+		return &rulesets, nil
+	}
+	if err := names.Decode(&rulesets); err != nil {
+		return nil, err
+	}
+	for k, v := range rulesets {
+		v.Name = k
+		rulesets[k] = v
+	}
+
+	return &rulesets, nil
+}
@@ -0,0 +1,53 @@
+package xedgeos
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testFirewallJSON = `{"success":true,"GET":{"firewall":{"all-ping":"enable","name":{"WAN_IN":{"default-action":"drop","description":"WAN to internal","enable-default-log":null,"rule":{"10":{"action":"accept","description":"Allow established/related","log":"disable","protocol":"all","state":{"established":"enable","invalid":"disable","new":"disable","related":"enable"}},"20":{"action":"drop","description":"Drop invalid state","log":"disable","protocol":"all","state":{"established":"disable","invalid":"enable","new":"disable","related":"disable"}},"30":{"action":"drop","description":"CrowdSec","log":"disable","protocol":"all","source":{"group":{"address-group":"CROWDSEC"}}}}},"WAN_LOCAL":{"default-action":"drop","description":"WAN to router","rule":{"20":{"action":"drop","description":"Block SSH","destination":{"group":{"address-group":"ADDRv4_eth0"},"port":"22"},"log":"enable","protocol":"tcp"}}}}}}}`
+
+func TestRulesets(t *testing.T) {
+	asrt := assert.New(t)
+	var res Resp
+
+	asrt.NoError(json.Unmarshal([]byte(testFirewallJSON), &res))
+
+	rulesets, err := NewRulesets(res)
+	asrt.NoError(err)
+	asrt.Len(*rulesets, 2)
+
+	wanIn, err := rulesets.GetRuleset("WAN_IN")
+	asrt.NoError(err)
+	asrt.Equal("WAN_IN", wanIn.Name)
+	asrt.Equal("drop", wanIn.DefaultAction)
+	asrt.Len(wanIn.Rules, 3)
+	asrt.Equal("enable", wanIn.Rules[10].State.Established)
+	asrt.True(wanIn.Rules[30].DropsSource(RuleGroup{AddressGroup: "CROWDSEC"}))
+	asrt.False(wanIn.Rules[30].DropsSource(RuleGroup{NetworkGroup: "CROWDSEC"}))
+	asrt.False(wanIn.Rules[20].DropsSource(RuleGroup{AddressGroup: "CROWDSEC"}))
+
+	wanLocal, err := rulesets.GetRuleset("WAN_LOCAL")
+	asrt.NoError(err)
+	asrt.Equal("22", wanLocal.Rules[20].Destination.Port)
+
+	_, err = rulesets.GetRuleset("LAN_IN")
+	asrt.Error(err)
+}
+
+func TestRuleSetData(t *testing.T) {
+	asrt := assert.New(t)
+
+	data, err := DropRule(RuleGroup{AddressGroup: "CROWDSEC"}, "CrowdSec").GetSetData("WAN_IN", 5)
+	asrt.NoError(err)
+
+	bs, err := json.Marshal(data)
+	asrt.NoError(err)
+	asrt.JSONEq(`{"firewall":{"name":{"WAN_IN":{"rule":{"5":{"action":"drop","description":"CrowdSec","source":{"group":{"address-group":"CROWDSEC"}}}}}}}}`, string(bs))
+
+	bs, err = json.Marshal(GetRuleDeleteData("WAN_IN", 5))
+	asrt.NoError(err)
+	asrt.JSONEq(`{"firewall":{"name":{"WAN_IN":{"rule":{"5":null}}}}}`, string(bs))
+}
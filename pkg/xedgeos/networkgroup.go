@@ -0,0 +1,165 @@
+package xedgeos
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+)
+
+// NetworkGroupCollection holds either the network-group or the
+// ipv6-network-group groups of a router.
+type NetworkGroupCollection map[string]NetworkGroup
+
+// NetworkGroup is a firewall group of network prefixes. Some firmware rejects
+// prefixes in address groups, so CIDR bans belong here.
+type NetworkGroup struct {
+	Name        string    `json:"-"`
+	Type        GroupType `json:"-"`
+	Description string    `json:"description,omitempty"`
+	Network     []string  `json:"network,omitempty"`
+}
+
+// path returns the configuration path of the group, followed by elem.
+func (n *NetworkGroup) path(elem ...string) []string {
+	t := n.Type
+	if t == "" {
+		t = NetworkGroupType
+	}
+
+	return append([]string{"firewall", "group", string(t), n.Name}, elem...)
+}
+
+func (n *NetworkGroup) Reset() {
+	n.Network = []string{}
+}
+
+func (n *NetworkGroup) Add(prefix string) bool {
+	i, has := slices.BinarySearch(n.Network, prefix)
+	if has {
+		return false
+	}
+
+	n.Network = slices.Insert(n.Network, i, prefix)
+
+	return true
+}
+
+func (n *NetworkGroup) Contains(prefix string) bool {
+	_, has := slices.BinarySearch(n.Network, prefix)
+	return has
+}
+
+func (n *NetworkGroup) Remove(prefix string) bool {
+	pos, has := slices.BinarySearch(n.Network, prefix)
+	if !has {
+		return false
+	}
+	n.Network = append(n.Network[:pos], n.Network[pos+1:]...)
+
+	return true
+}
+
+// SetData returns the data needed to add prefixes to the group.
+func (n *NetworkGroup) SetData(values []string) map[string]any {
+	return SetData(n.path("network"), values)
+}
+
+// DeleteData returns the data needed to remove prefixes from the group.
+func (n *NetworkGroup) DeleteData(values []string) map[string]any {
+	return DeleteData(n.path("network"), values...)
+}
+
+// GetSetData returns the prefixes in group that are missing from our
+// collection, in batches of DefaultBatchSize.
+func (c *NetworkGroupCollection) GetSetData(group *NetworkGroup) ([]map[string]any, error) {
+	return c.GetSetBatches(group, DefaultBatchSize)
+}
+
+// GetSetBatches is GetSetData with size prefixes per batch.
+func (c *NetworkGroupCollection) GetSetBatches(group *NetworkGroup, size int) ([]map[string]any, error) {
+	ourGroup, ok := (*c)[group.Name]
+	if !ok {
+		return nil, fmt.Errorf("group %s not found", group.Name)
+	}
+
+	return setData(ourGroup.SetData, ourGroup.Network, group.Network, size), nil
+}
+
+// GetDeleteData returns the prefixes in our collection that are missing from
+// group, in batches of DefaultBatchSize.
+func (c *NetworkGroupCollection) GetDeleteData(group *NetworkGroup) ([]map[string]any, error) {
+	return c.GetDeleteBatches(group, DefaultBatchSize)
+}
+
+// GetDeleteBatches is GetDeleteData with size prefixes per batch.
+func (c *NetworkGroupCollection) GetDeleteBatches(group *NetworkGroup, size int) ([]map[string]any, error) {
+	if !slices.IsSorted(group.Network) {
+		slog.Debug("sorting group", "group", group.Name)
+		slices.Sort(group.Network)
+	}
+
+	ourGroup, ok := (*c)[group.Name]
+	if !ok {
+		return nil, fmt.Errorf("group %s not found", group.Name)
+	}
+
+	return deleteData(ourGroup.DeleteData, ourGroup.Network, group.Network, size), nil
+}
+
+// GetCreateData returns the data needed to create the group with its
+// description. Prefixes are not included, use GetSetData for those.
+func (c *NetworkGroupCollection) GetCreateData(group *NetworkGroup) map[string]any {
+	return SetData(group.path("description"), group.Description)
+}
+
+func (c *NetworkGroupCollection) UpdateGroup(group *NetworkGroup) error {
+	_, ok := (*c)[group.Name]
+	if !ok {
+		return fmt.Errorf("group %s not found", group.Name)
+	}
+
+	(*c)[group.Name] = *group
+
+	return nil
+}
+
+func (c *NetworkGroupCollection) GetGroup(name string) (*NetworkGroup, error) {
+	tmp, ok := (*c)[name]
+	if !ok {
+		return nil, fmt.Errorf("group %s not found", name)
+	}
+
+	tmp.Name = name
+
+	return &tmp, nil
+}
+
+// NewNetworkGroups parses the network-group groups from the response from
+// Get.
+func NewNetworkGroups(in map[string]any) (*NetworkGroupCollection, error) {
+	return newNetworkGroups(in, NetworkGroupType)
+}
+
+// NewIPv6NetworkGroups parses the ipv6-network-group groups from the response
+// from Get.
+func NewIPv6NetworkGroups(in map[string]any) (*NetworkGroupCollection, error) {
+	return newNetworkGroups(in, IPv6NetworkGroupType)
+}
+
+func newNetworkGroups(in map[string]any, t GroupType) (*NetworkGroupCollection, error) {
+	networkGroups := NetworkGroupCollection{}
+
+	err := eachGroup(in, t, func(name, description string, values []string) {
+		networkGroups[name] = NetworkGroup{
+			Name:        name,
+			Type:        t,
+			Description: description,
+			Network:     values,
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &networkGroups, nil
+}
@@ -0,0 +1,49 @@
+package xedgeos
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testNetworkGroupJSON = `{"success":true,"GET":{"firewall":{"group":{"network-group":{"CROWDSEC_NETS":{"description":"CrowdSec ranges","network":["10.0.0.0/8","192.0.2.0/24"]}},"ipv6-network-group":{"CROWDSEC_V6":{"network":"2001:db8::/32"}}}}}}`
+
+func TestNetworkGroups(t *testing.T) {
+	asrt := assert.New(t)
+	var res Resp
+	asrt.NoError(json.Unmarshal([]byte(testNetworkGroupJSON), &res))
+
+	ng, err := NewNetworkGroups(res)
+	asrt.NoError(err)
+	group, err := ng.GetGroup("CROWDSEC_NETS")
+	asrt.NoError(err)
+	asrt.Equal(NetworkGroupType, group.Type)
+	asrt.Equal([]string{"10.0.0.0/8", "192.0.2.0/24"}, group.Network)
+
+	asrt.True(group.Add("198.51.100.0/24"))
+	asrt.False(group.Add("10.0.0.0/8"))
+	asrt.True(group.Remove("192.0.2.0/24"))
+
+	setData, err := ng.GetSetData(group)
+	asrt.NoError(err)
+	bs, _ := json.Marshal(setData)
+	asrt.JSONEq(`[{"firewall":{"group":{"network-group":{"CROWDSEC_NETS":{"network":["198.51.100.0/24"]}}}}}]`, string(bs))
+
+	delData, err := ng.GetDeleteData(group)
+	asrt.NoError(err)
+	bs, _ = json.Marshal(delData)
+	asrt.JSONEq(`[{"firewall":{"group":{"network-group":{"CROWDSEC_NETS":{"network":["192.0.2.0/24"]}}}}}]`, string(bs))
+
+	v6, err := NewIPv6NetworkGroups(res)
+	asrt.NoError(err)
+	group, err = v6.GetGroup("CROWDSEC_V6")
+	asrt.NoError(err)
+	asrt.Equal([]string{"2001:db8::/32"}, group.Network)
+
+	group.Reset()
+	delData, err = v6.GetDeleteData(group)
+	asrt.NoError(err)
+	bs, _ = json.Marshal(delData)
+	asrt.JSONEq(`[{"firewall":{"group":{"ipv6-network-group":{"CROWDSEC_V6":{"network":["2001:db8::/32"]}}}}}]`, string(bs))
+}
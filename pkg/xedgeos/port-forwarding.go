@@ -1,5 +1,11 @@
 package xedgeos
 
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
 // PortForward is a struct that represents a port forwarding rule
 type PortForward struct {
 	PortFrom    string `json:"original-port"`
@@ -23,30 +29,119 @@ type PortForwards struct {
 // data.
 type LanConfig map[string]string
 
-// Response is a struct (intended for embedding) that represents response
-// metadata returned by the EdgeOS API.
-type Response struct {
-	Success bool   `json:"success"`
-	Error   string `json:"error"`
+// FeatureResponse is the feature endpoint response for port forwarding.
+type FeatureResponse = FeatureResponseOf[PortForwards]
+
+// Feature is the port forwarding feature data.
+type Feature = FeatureOf[PortForwards]
+
+// PortForwards loads the router's port forwarding rules.
+func (c *Client) PortForwards() (*FeatureResponse, error) {
+	return LoadFeature[PortForwards](c, PortForwarding)
 }
 
-// FeatureResponse encapsulates the Response metadata and the Feature
-type FeatureResponse struct {
-	Response
-	Feature Feature `json:"FEATURE"`
+// Port forwarding protocols accepted by EdgeOS
+const (
+	ProtocolTCP    = "tcp"
+	ProtocolUDP    = "udp"
+	ProtocolTCPUDP = "tcp_udp"
+)
+
+// Validate checks that the rule has the fields EdgeOS requires and a known
+// protocol.
+func (p PortForward) Validate() error {
+	switch p.Protocol {
+	case ProtocolTCP, ProtocolUDP, ProtocolTCPUDP:
+	default:
+		return fmt.Errorf("invalid protocol %q for port %s", p.Protocol, p.PortFrom)
+	}
+	if p.PortFrom == "" {
+		return errors.New("original-port is required")
+	}
+	if p.IPTo == "" {
+		return fmt.Errorf("forward-to-address is required for port %s", p.PortFrom)
+	}
+
+	return nil
 }
 
-type Feature struct {
-	Data PortForwards
-	// Data       interface{}
-	Definition interface{}
+// overlaps reports whether two protocols share any traffic, tcp_udp overlaps
+// with both tcp and udp.
+func overlaps(a, b string) bool {
+	return a == b || a == ProtocolTCPUDP || b == ProtocolTCPUDP
+}
+
+func (p *PortForwards) index(port, protocol string) int {
+	for i, r := range p.Rules {
+		if r.PortFrom == port && r.Protocol == protocol {
+			return i
+		}
+	}
 
-	Deletable string
-	Success   string
+	return -1
 }
 
-func (c *Client) PortForwards() (*FeatureResponse, error) {
-	res := &FeatureResponse{}
-	err := c.FeatureFor(PortForwarding, res)
-	return res, err
+// Get returns the rule forwarding the original port and protocol.
+func (p *PortForwards) Get(port, protocol string) (PortForward, bool) {
+	i := p.index(port, protocol)
+	if i < 0 {
+		return PortForward{}, false
+	}
+
+	return p.Rules[i], true
+}
+
+// Add appends the rule. It is an error to add a rule that overlaps the
+// original port and protocol of an existing rule.
+func (p *PortForwards) Add(rule PortForward) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	for _, r := range p.Rules {
+		if r.PortFrom == rule.PortFrom && overlaps(r.Protocol, rule.Protocol) {
+			return fmt.Errorf("port %s/%s is already forwarded by %q", rule.PortFrom, rule.Protocol, r.Description)
+		}
+	}
+	p.Rules = append(p.Rules, rule)
+
+	return nil
+}
+
+// Update replaces the rule with the same original port and protocol.
+func (p *PortForwards) Update(rule PortForward) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	i := p.index(rule.PortFrom, rule.Protocol)
+	if i < 0 {
+		return fmt.Errorf("port %s/%s is not forwarded", rule.PortFrom, rule.Protocol)
+	}
+	p.Rules[i] = rule
+
+	return nil
+}
+
+// Remove deletes the rule with the original port and protocol, returning
+// true if it existed.
+func (p *PortForwards) Remove(port, protocol string) bool {
+	i := p.index(port, protocol)
+	if i < 0 {
+		return false
+	}
+	p.Rules = slices.Delete(p.Rules, i, i+1)
+
+	return true
+}
+
+// SetPortForwards validates and applies the port forwarding configuration.
+// The whole table is replaced, so callers should load it with PortForwards,
+// modify it, then pass it back here.
+func (c *Client) SetPortForwards(pf PortForwards) (*FeatureResponse, error) {
+	for _, r := range pf.Rules {
+		if err := r.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return ApplyFeature(c, PortForwarding, pf)
 }
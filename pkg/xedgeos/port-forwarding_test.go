@@ -2,6 +2,9 @@ package xedgeos
 
 import (
 	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
@@ -23,3 +26,78 @@ func TestDecode(t *testing.T) {
 
 	asrt.Len(res.Feature.Data.Rules, 7)
 }
+
+func TestPortForwardRoundTrip(t *testing.T) {
+	asrt := assert.New(t)
+	var res FeatureResponse
+	asrt.NoError(json.Unmarshal([]byte(testJSON), &res))
+	pf := res.Feature.Data
+
+	bs, err := json.Marshal(pf)
+	asrt.NoError(err)
+	var again PortForwards
+	asrt.NoError(json.Unmarshal(bs, &again))
+	asrt.Equal(pf, again)
+}
+
+func TestPortForwardEdit(t *testing.T) {
+	asrt := assert.New(t)
+	var res FeatureResponse
+	asrt.NoError(json.Unmarshal([]byte(testJSON), &res))
+	pf := res.Feature.Data
+
+	rule, ok := pf.Get("2223", ProtocolTCPUDP)
+	asrt.True(ok)
+	asrt.Equal("Desk SSH", rule.Description)
+
+	// tcp_udp overlaps with tcp on the same port
+	asrt.Error(pf.Add(PortForward{PortFrom: "53", PortTo: "53", IPTo: "192.168.16.6", Protocol: ProtocolTCP}))
+	asrt.Error(pf.Add(PortForward{PortFrom: "80", PortTo: "80", IPTo: "192.168.16.6", Protocol: "icmp"}))
+	asrt.NoError(pf.Add(PortForward{PortFrom: "80", PortTo: "8080", IPTo: "192.168.16.6", Protocol: ProtocolTCP, Description: "Web"}))
+	asrt.Len(pf.Rules, 8)
+
+	rule.IPTo = "192.168.16.13"
+	asrt.NoError(pf.Update(rule))
+	rule, _ = pf.Get("2223", ProtocolTCPUDP)
+	asrt.Equal("192.168.16.13", rule.IPTo)
+	asrt.Error(pf.Update(PortForward{PortFrom: "9999", IPTo: "192.168.16.1", Protocol: ProtocolUDP}))
+
+	asrt.True(pf.Remove("1195", ProtocolUDP))
+	asrt.False(pf.Remove("1195", ProtocolUDP))
+	asrt.Len(pf.Rules, 7)
+}
+
+func TestSetPortForwards(t *testing.T) {
+	asrt := assert.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Data struct {
+				Action   string          `json:"action"`
+				Scenario string          `json:"scenario"`
+				Apply    json.RawMessage `json:"apply"`
+			} `json:"data"`
+		}
+		asrt.NoError(json.NewDecoder(r.Body).Decode(&req))
+		asrt.Equal("apply", req.Data.Action)
+		asrt.Equal(string(PortForwarding), req.Data.Scenario)
+
+		fmt.Fprintf(w, `{"success":true,"FEATURE":{"data":%s,"success":"1"}}`, req.Data.Apply)
+	}))
+	defer srv.Close()
+
+	var res FeatureResponse
+	asrt.NoError(json.Unmarshal([]byte(testJSON), &res))
+	pf := res.Feature.Data
+	asrt.True(pf.Remove("5431", ProtocolTCPUDP))
+
+	c, err := NewClient(srv.URL, "ubnt", "ubnt")
+	asrt.NoError(err)
+	out, err := c.SetPortForwards(pf)
+	asrt.NoError(err)
+	asrt.Equal(pf, out.Feature.Data)
+
+	pf.Rules[0].Protocol = "sctp"
+	_, err = c.SetPortForwards(pf)
+	asrt.Error(err)
+}
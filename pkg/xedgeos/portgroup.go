@@ -0,0 +1,153 @@
+package xedgeos
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+)
+
+// PortGroupCollection holds the port-group groups of a router.
+type PortGroupCollection map[string]PortGroup
+
+// PortGroup is a firewall group of ports. Members may be port numbers, ranges
+// such as "8000-8100" or service names such as "ssh".
+type PortGroup struct {
+	Name        string   `json:"-"`
+	Description string   `json:"description,omitempty"`
+	Port        []string `json:"port,omitempty"`
+}
+
+// path returns the configuration path of the group, followed by elem.
+func (p *PortGroup) path(elem ...string) []string {
+	return append([]string{"firewall", "group", string(PortGroupType), p.Name}, elem...)
+}
+
+func (p *PortGroup) Reset() {
+	p.Port = []string{}
+}
+
+func (p *PortGroup) Add(port string) bool {
+	i, has := slices.BinarySearch(p.Port, port)
+	if has {
+		return false
+	}
+
+	p.Port = slices.Insert(p.Port, i, port)
+
+	return true
+}
+
+func (p *PortGroup) Contains(port string) bool {
+	_, has := slices.BinarySearch(p.Port, port)
+	return has
+}
+
+func (p *PortGroup) Remove(port string) bool {
+	pos, has := slices.BinarySearch(p.Port, port)
+	if !has {
+		return false
+	}
+	p.Port = append(p.Port[:pos], p.Port[pos+1:]...)
+
+	return true
+}
+
+// SetData returns the data needed to add ports to the group.
+func (p *PortGroup) SetData(values []string) map[string]any {
+	return SetData(p.path("port"), values)
+}
+
+// DeleteData returns the data needed to remove ports from the group.
+func (p *PortGroup) DeleteData(values []string) map[string]any {
+	return DeleteData(p.path("port"), values...)
+}
+
+// GetSetData returns the ports in group that are missing from our collection,
+// in batches of DefaultBatchSize.
+func (c *PortGroupCollection) GetSetData(group *PortGroup) ([]map[string]any, error) {
+	return c.GetSetBatches(group, DefaultBatchSize)
+}
+
+// GetSetBatches is GetSetData with size ports per batch.
+func (c *PortGroupCollection) GetSetBatches(group *PortGroup, size int) ([]map[string]any, error) {
+	ourGroup, ok := (*c)[group.Name]
+	if !ok {
+		return nil, fmt.Errorf("group %s not found", group.Name)
+	}
+
+	return setData(group.SetData, ourGroup.Port, group.Port, size), nil
+}
+
+// GetDeleteData returns the ports in our collection that are missing from
+// group, in batches of DefaultBatchSize.
+func (c *PortGroupCollection) GetDeleteData(group *PortGroup) ([]map[string]any, error) {
+	return c.GetDeleteBatches(group, DefaultBatchSize)
+}
+
+// GetDeleteBatches is GetDeleteData with size ports per batch.
+func (c *PortGroupCollection) GetDeleteBatches(group *PortGroup, size int) ([]map[string]any, error) {
+	if !slices.IsSorted(group.Port) {
+		slog.Debug("sorting group", "group", group.Name)
+		slices.Sort(group.Port)
+	}
+
+	ourGroup, ok := (*c)[group.Name]
+	if !ok {
+		return nil, fmt.Errorf("group %s not found", group.Name)
+	}
+
+	return deleteData(group.DeleteData, ourGroup.Port, group.Port, size), nil
+}
+
+// GetCreateData returns the data needed to create the group with its
+// description and ports.
+func (c *PortGroupCollection) GetCreateData(group *PortGroup) map[string]any {
+	data := map[string]any{
+		"description": group.Description,
+	}
+	if len(group.Port) > 0 {
+		data["port"] = group.Port
+	}
+
+	return SetData(group.path(), data)
+}
+
+func (c *PortGroupCollection) UpdateGroup(group *PortGroup) error {
+	_, ok := (*c)[group.Name]
+	if !ok {
+		return fmt.Errorf("group %s not found", group.Name)
+	}
+
+	(*c)[group.Name] = *group
+
+	return nil
+}
+
+func (c *PortGroupCollection) GetGroup(name string) (*PortGroup, error) {
+	tmp, ok := (*c)[name]
+	if !ok {
+		return nil, fmt.Errorf("group %s not found", name)
+	}
+
+	tmp.Name = name
+
+	return &tmp, nil
+}
+
+// NewPortGroups parses the port-group groups from the response from Get.
+func NewPortGroups(in map[string]any) (*PortGroupCollection, error) {
+	portGroups := PortGroupCollection{}
+
+	err := eachGroup(in, PortGroupType, func(name, description string, values []string) {
+		portGroups[name] = PortGroup{
+			Name:        name,
+			Description: description,
+			Port:        values,
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &portGroups, nil
+}
@@ -0,0 +1,39 @@
+package xedgeos
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testPortGroupJSON = `{"success":true,"GET":{"firewall":{"group":{"port-group":{"SSH":{"description":"SSH ports","port":["22","2222"]},"WEB":{"port":"443"}}}}}}`
+
+func TestPortGroups(t *testing.T) {
+	asrt := assert.New(t)
+	var res Resp
+	asrt.NoError(json.Unmarshal([]byte(testPortGroupJSON), &res))
+
+	pg, err := NewPortGroups(res)
+	asrt.NoError(err)
+	asrt.Equal([]string{"443"}, (*pg)["WEB"].Port)
+
+	group, err := pg.GetGroup("SSH")
+	asrt.NoError(err)
+	asrt.Equal("SSH ports", group.Description)
+	asrt.True(group.Add("8022"))
+	asrt.True(group.Remove("2222"))
+
+	setData, err := pg.GetSetData(group)
+	asrt.NoError(err)
+	bs, _ := json.Marshal(setData)
+	asrt.JSONEq(`[{"firewall":{"group":{"port-group":{"SSH":{"port":["8022"]}}}}}]`, string(bs))
+
+	delData, err := pg.GetDeleteData(group)
+	asrt.NoError(err)
+	bs, _ = json.Marshal(delData)
+	asrt.JSONEq(`[{"firewall":{"group":{"port-group":{"SSH":{"port":["2222"]}}}}}]`, string(bs))
+
+	bs, _ = json.Marshal(pg.GetCreateData(&PortGroup{Name: "NEW", Description: "new", Port: []string{"25"}}))
+	asrt.JSONEq(`{"firewall":{"group":{"port-group":{"NEW":{"description":"new","port":["25"]}}}}}`, string(bs))
+}
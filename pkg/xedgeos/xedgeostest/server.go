@@ -0,0 +1,505 @@
+// Package xedgeostest provides an in-process fake EdgeOS router for testing
+// code built on xedgeos without a real device.
+//
+// The fake implements the parts of the API the bouncer relies on: form login
+// with session and CSRF cookies, session expiry, and the get, set, delete and
+// batch endpoints over an in-memory configuration tree. Features and data
+// sets can be seeded, and failures and rejected changes injected per
+// endpoint.
+package xedgeostest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jacobalberty/cs-edgeos-bouncer/pkg/xedgeos"
+)
+
+// Endpoint names accepted by FailNext, RejectNext and Requests.
+const (
+	EndpointLogin   = "login"
+	EndpointGet     = "get"
+	EndpointSet     = "set"
+	EndpointDelete  = "delete"
+	EndpointBatch   = "batch"
+	EndpointFeature = "feature"
+	EndpointData    = "data"
+)
+
+const (
+	sessionCookie = "PHPSESSID"
+	csrfCookie    = "X-CSRF-TOKEN"
+	csrfHeader    = "X-CSRF-Token"
+)
+
+type session struct {
+	csrf    string
+	expires time.Time
+}
+
+// Server is a fake EdgeOS router. Its exported fields may be changed before
+// the first request is made.
+type Server struct {
+	*httptest.Server
+
+	Username, Password string
+	// SessionTTL is how long a session may sit idle before it expires. Zero
+	// means sessions never expire on their own.
+	SessionTTL time.Duration
+
+	mu       sync.Mutex
+	config   map[string]any
+	sessions map[string]session
+	failures map[string]int
+	rejects  map[string]int
+	requests map[string]int
+	features map[xedgeos.Scenario]any
+	data     map[string]any
+}
+
+// NewServer starts a fake router holding cfg, which has the same shape as the
+// GET node of the get endpoint. The credentials default to ubnt/ubnt.
+func NewServer(cfg map[string]any) *Server {
+	s := &Server{
+		Username: "ubnt",
+		Password: "ubnt",
+		config:   normalize(cfg),
+		sessions: map[string]session{},
+		failures: map[string]int{},
+		rejects:  map[string]int{},
+		requests: map[string]int{},
+		features: map[xedgeos.Scenario]any{},
+		data:     map[string]any{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	return s
+}
+
+// Config returns a copy of the router's current configuration.
+func (s *Server) Config() map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return normalize(s.config)
+}
+
+// Lookup returns the configuration node at path.
+func (s *Server) Lookup(path ...string) (xedgeos.Node, bool) {
+	tree, _ := xedgeos.NewConfigTree(map[string]any{"GET": s.Config()})
+
+	return tree.Lookup(path...)
+}
+
+// SetFeature sets the data returned when the scenario is loaded.
+func (s *Server) SetFeature(sc xedgeos.Scenario, data any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.features[sc] = data
+}
+
+// Feature returns the scenario's current data, as last loaded or applied.
+func (s *Server) Feature(sc xedgeos.Scenario) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.features[sc]
+}
+
+// SetData sets the output returned for the named data set.
+func (s *Server) SetData(name string, output any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data[name] = output
+}
+
+// FailNext makes the next n requests to the endpoint fail with a 500.
+func (s *Server) FailNext(endpoint string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures[endpoint] += n
+}
+
+// RejectNext makes the router refuse the next n changes to the set, delete
+// or batch endpoint. They are answered with a 200 whose body reports the
+// failure, the way EdgeOS answers an invalid change, and are not applied.
+func (s *Server) RejectNext(endpoint string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rejects[endpoint] += n
+}
+
+// Recover cancels any failures and rejections still pending for the
+// endpoint.
+func (s *Server) Recover(endpoint string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.failures, endpoint)
+	delete(s.rejects, endpoint)
+}
+
+// ExpireSessions invalidates every session so clients must log in again.
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clear(s.sessions)
+}
+
+// Requests returns the number of requests made to the endpoint, including
+// failed ones.
+func (s *Server) Requests(endpoint string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[endpoint]
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.URL.Path == "/" && r.Method == http.MethodPost {
+		s.requests[EndpointLogin]++
+		if s.fail(w, EndpointLogin) {
+			return
+		}
+		s.login(w, r)
+		return
+	}
+
+	name, ok := strings.CutPrefix(r.URL.EscapedPath(), "/api/edge/")
+	if !ok || !strings.HasSuffix(name, ".json") {
+		http.NotFound(w, r)
+		return
+	}
+	name = strings.TrimSuffix(name, ".json")
+	endpoint, path, _ := strings.Cut(name, "/")
+
+	s.requests[endpoint]++
+	if s.fail(w, endpoint) {
+		return
+	}
+	if status, msg := s.authorize(r); status != http.StatusOK {
+		writeJSON(w, status, map[string]any{"success": false, "error": msg})
+		return
+	}
+
+	switch endpoint {
+	case EndpointGet:
+		s.get(w, path)
+	case EndpointSet, EndpointDelete, EndpointBatch:
+		s.change(w, r, endpoint)
+	case EndpointFeature:
+		s.feature(w, r)
+	case EndpointData:
+		s.dataSet(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) fail(w http.ResponseWriter, endpoint string) bool {
+	if s.failures[endpoint] == 0 {
+		return false
+	}
+	s.failures[endpoint]--
+	writeJSON(w, http.StatusInternalServerError, map[string]any{"success": false, "error": "injected failure"})
+
+	return true
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	if r.PostFormValue("username") != s.Username || r.PostFormValue("password") != s.Password {
+		http.Error(w, "invalid credentials", http.StatusForbidden)
+		return
+	}
+
+	id, csrf := token(), token()
+	s.sessions[id] = session{csrf: csrf, expires: s.expiry()}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: id, Path: "/"})
+	http.SetCookie(w, &http.Cookie{Name: csrfCookie, Value: csrf, Path: "/"})
+	w.WriteHeader(http.StatusOK)
+}
+
+// authorize checks the request's session, and for POSTs its CSRF token,
+// returning the status to respond with.
+func (s *Server) authorize(r *http.Request) (int, string) {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return http.StatusUnauthorized, "not logged in"
+	}
+	sess, ok := s.sessions[c.Value]
+	if !ok {
+		return http.StatusUnauthorized, "session expired"
+	}
+	if !sess.expires.IsZero() && time.Now().After(sess.expires) {
+		delete(s.sessions, c.Value)
+		return http.StatusUnauthorized, "session expired"
+	}
+	if r.Method == http.MethodPost && r.Header.Get(csrfHeader) != sess.csrf {
+		return http.StatusForbidden, "invalid CSRF token"
+	}
+
+	sess.expires = s.expiry()
+	s.sessions[c.Value] = sess
+
+	return http.StatusOK, ""
+}
+
+func (s *Server) expiry() time.Time {
+	if s.SessionTTL == 0 {
+		return time.Time{}
+	}
+
+	return time.Now().Add(s.SessionTTL)
+}
+
+func (s *Server) get(w http.ResponseWriter, path string) {
+	out := map[string]any{}
+	if path == "" {
+		out = normalize(s.config)
+	} else {
+		var elems []string
+		for _, p := range strings.Split(path, "/") {
+			p, err := url.PathUnescape(p)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "error": err.Error()})
+				return
+			}
+			elems = append(elems, p)
+		}
+		if v, ok := lookup(s.config, elems); ok {
+			out = normalize(xedgeos.SetData(elems, v))
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"GET": out, "success": true})
+}
+
+func (s *Server) change(w http.ResponseWriter, r *http.Request, endpoint string) {
+	var body map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "error": err.Error()})
+		return
+	}
+
+	if s.rejects[endpoint] > 0 {
+		s.rejects[endpoint]--
+		failed := map[string]any{"success": "0", "failure": "1", "error": map[string]any{"": "injected rejection"}}
+		res := map[string]any{"success": "0"}
+		switch endpoint {
+		case EndpointSet:
+			res["SET"] = failed
+		case EndpointDelete:
+			res["DELETE"] = failed
+		case EndpointBatch:
+			for _, op := range []string{"DELETE", "SET"} {
+				if _, ok := body[op]; ok {
+					res[op] = failed
+				}
+			}
+		}
+		writeJSON(w, http.StatusOK, res)
+		return
+	}
+
+	ok := map[string]any{"success": "1", "failure": "0"}
+	res := map[string]any{"success": "1", "COMMIT": ok, "SAVE": map[string]any{"success": "1"}}
+	switch endpoint {
+	case EndpointSet:
+		merge(s.config, body)
+		res["SET"] = ok
+	case EndpointDelete:
+		remove(s.config, body)
+		res["DELETE"] = ok
+	case EndpointBatch:
+		if del, _ := body["DELETE"].(map[string]any); del != nil {
+			remove(s.config, del)
+			res["DELETE"] = ok
+		}
+		if set, _ := body["SET"].(map[string]any); set != nil {
+			merge(s.config, set)
+			res["SET"] = ok
+		}
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) feature(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Data struct {
+			Action   string           `json:"action"`
+			Scenario xedgeos.Scenario `json:"scenario"`
+			Apply    json.RawMessage  `json:"apply"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "error": err.Error()})
+		return
+	}
+
+	if req.Data.Action == "apply" {
+		var data any
+		if err := json.Unmarshal(req.Data.Apply, &data); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "error": err.Error()})
+			return
+		}
+		s.features[req.Data.Scenario] = data
+	}
+	data, ok := s.features[req.Data.Scenario]
+	if !ok {
+		writeJSON(w, http.StatusOK, map[string]any{"success": false, "error": "unknown scenario"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"FEATURE": map[string]any{"data": data, "success": "1"},
+	})
+}
+
+func (s *Server) dataSet(w http.ResponseWriter, r *http.Request) {
+	output, ok := s.data[r.URL.Query().Get("data")]
+	if !ok {
+		writeJSON(w, http.StatusOK, map[string]any{"success": "0", "error": "unknown data"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"success": "1", "output": output})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func token() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// normalize deep copies v into the shapes decoded JSON takes, so callers can
+// pass []string and the like.
+func normalize(v map[string]any) map[string]any {
+	out := map[string]any{}
+	if v == nil {
+		return out
+	}
+	bs, _ := json.Marshal(v)
+	json.Unmarshal(bs, &out)
+
+	return out
+}
+
+func lookup(cfg map[string]any, path []string) (any, bool) {
+	var cur any = cfg
+	for _, p := range path {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[p]; !ok {
+			return nil, false
+		}
+	}
+
+	return cur, true
+}
+
+// merge applies set data to cfg. Lists add to multi-valued leaves rather
+// than replacing them, as the set endpoint does.
+func merge(cfg, data map[string]any) {
+	for k, v := range data {
+		switch v := v.(type) {
+		case map[string]any:
+			child, ok := cfg[k].(map[string]any)
+			if !ok {
+				child = map[string]any{}
+				cfg[k] = child
+			}
+			merge(child, v)
+		case []any:
+			cur := values(cfg[k])
+			for _, n := range values(v) {
+				if !slices.Contains(cur, n) {
+					cur = append(cur, n)
+				}
+			}
+			cfg[k] = toAny(cur)
+		default:
+			cfg[k] = v
+		}
+	}
+}
+
+// remove applies delete data to cfg. A null removes the whole node, values
+// remove only themselves from a multi-valued leaf.
+func remove(cfg, data map[string]any) {
+	for k, v := range data {
+		switch v := v.(type) {
+		case map[string]any:
+			if child, ok := cfg[k].(map[string]any); ok {
+				remove(child, v)
+			}
+		case []any, string:
+			if _, ok := cfg[k]; !ok {
+				continue
+			}
+			drop := values(v)
+			left := slices.DeleteFunc(values(cfg[k]), func(s string) bool {
+				return slices.Contains(drop, s)
+			})
+			if len(left) == 0 {
+				delete(cfg, k)
+			} else {
+				cfg[k] = toAny(left)
+			}
+		default:
+			delete(cfg, k)
+		}
+	}
+}
+
+func values(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+func toAny(values []string) []any {
+	out := make([]any, len(values))
+	for i, v := range values {
+		out[i] = v
+	}
+
+	return out
+}
@@ -0,0 +1,211 @@
+package xedgeostest
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jacobalberty/cs-edgeos-bouncer/pkg/xedgeos"
+	"github.com/stretchr/testify/assert"
+)
+
+var testConfig = map[string]any{
+	"firewall": map[string]any{
+		"group": map[string]any{
+			"address-group": map[string]any{
+				"CROWDSEC": map[string]any{
+					"description": "CrowdSec bouncer",
+					"address":     []string{"198.51.100.1", "198.51.100.2"},
+				},
+			},
+		},
+		"name": map[string]any{
+			"WAN_IN": map[string]any{"default-action": "drop"},
+		},
+	},
+}
+
+func newClient(t *testing.T, srv *Server) *xedgeos.Client {
+	c, err := xedgeos.NewClient(srv.URL, "ubnt", "ubnt")
+	assert.NoError(t, err)
+
+	return c
+}
+
+func groupAddresses(srv *Server, name string) []string {
+	n, _ := srv.Lookup("firewall", "group", "address-group", name, "address")
+	return n.Values()
+}
+
+func TestLogin(t *testing.T) {
+	asrt := assert.New(t)
+	srv := NewServer(testConfig)
+	defer srv.Close()
+
+	c, err := xedgeos.NewClient(srv.URL, "ubnt", "wrong")
+	asrt.NoError(err)
+	asrt.Error(c.Login())
+	_, err = c.Get()
+	asrt.Error(err, "requests need a session")
+
+	c = newClient(t, srv)
+	asrt.NoError(c.Login())
+	res, err := c.GetPath("firewall", "group")
+	asrt.NoError(err)
+	ag, err := xedgeos.NewAddressGroups(res)
+	asrt.NoError(err)
+	asrt.Equal([]string{"198.51.100.1", "198.51.100.2"}, (*ag)["CROWDSEC"].Address)
+	asrt.Equal(2, srv.Requests(EndpointLogin))
+}
+
+func TestCSRF(t *testing.T) {
+	asrt := assert.New(t)
+	srv := NewServer(testConfig)
+	defer srv.Close()
+	c := newClient(t, srv)
+	asrt.NoError(c.Login())
+
+	// A session alone is not enough to make changes.
+	jar, _ := cookiejar.New(nil)
+	raw := &http.Client{Jar: jar}
+	res, err := raw.PostForm(srv.URL+"/", url.Values{"username": {"ubnt"}, "password": {"ubnt"}})
+	asrt.NoError(err)
+	res.Body.Close()
+	res, err = raw.Post(c.Endpoint("set"), "application/json", strings.NewReader(`{}`))
+	asrt.NoError(err)
+	res.Body.Close()
+	asrt.Equal(http.StatusForbidden, res.StatusCode)
+
+	// The client picks the token up from the login cookie.
+	_, err = c.Set(xedgeos.SetData(xedgeos.ParsePath("system host-name"), "router"))
+	asrt.NoError(err)
+	n, ok := srv.Lookup("system", "host-name")
+	asrt.True(ok)
+	v, _ := n.Value()
+	asrt.Equal("router", v)
+}
+
+func TestSetDelete(t *testing.T) {
+	asrt := assert.New(t)
+	srv := NewServer(testConfig)
+	defer srv.Close()
+	c := newClient(t, srv)
+	asrt.NoError(c.Login())
+
+	path := xedgeos.ParsePath("firewall group address-group CROWDSEC address")
+	_, err := c.Set(xedgeos.SetData(path, []string{"198.51.100.2", "198.51.100.3"}))
+	asrt.NoError(err)
+	asrt.Equal([]string{"198.51.100.1", "198.51.100.2", "198.51.100.3"}, groupAddresses(srv, "CROWDSEC"))
+
+	_, err = c.Delete(xedgeos.DeleteData(path, "198.51.100.1"))
+	asrt.NoError(err)
+	asrt.Equal([]string{"198.51.100.2", "198.51.100.3"}, groupAddresses(srv, "CROWDSEC"))
+
+	_, err = c.Batch(xedgeos.BatchData{
+		Delete: xedgeos.DeleteData(path, "198.51.100.2", "198.51.100.3"),
+		Set:    xedgeos.SetData(path, []string{"198.51.100.4"}),
+	})
+	asrt.NoError(err)
+	asrt.Equal([]string{"198.51.100.4"}, groupAddresses(srv, "CROWDSEC"))
+
+	_, err = c.Delete(xedgeos.DeleteData(xedgeos.ParsePath("firewall group address-group CROWDSEC")))
+	asrt.NoError(err)
+	_, ok := srv.Lookup("firewall", "group", "address-group", "CROWDSEC")
+	asrt.False(ok)
+	_, ok = srv.Lookup("firewall", "name", "WAN_IN")
+	asrt.True(ok)
+}
+
+func TestSessionExpiry(t *testing.T) {
+	asrt := assert.New(t)
+	srv := NewServer(testConfig)
+	defer srv.Close()
+	c := newClient(t, srv)
+	asrt.NoError(c.Login())
+
+	srv.ExpireSessions()
+	_, err := c.Get()
+	asrt.Error(err)
+	asrt.NoError(c.Login())
+	_, err = c.Get()
+	asrt.NoError(err)
+
+	idle := NewServer(testConfig)
+	defer idle.Close()
+	idle.SessionTTL = time.Millisecond
+	c = newClient(t, idle)
+	asrt.NoError(c.Login())
+	time.Sleep(5 * time.Millisecond)
+	_, err = c.Get()
+	asrt.Error(err)
+}
+
+func TestFailNext(t *testing.T) {
+	asrt := assert.New(t)
+	srv := NewServer(testConfig)
+	defer srv.Close()
+	c := newClient(t, srv)
+
+	srv.FailNext(EndpointLogin, 1)
+	asrt.Error(c.Login())
+	asrt.NoError(c.Login())
+
+	srv.FailNext(EndpointSet, 1)
+	data := xedgeos.SetData(xedgeos.ParsePath("system host-name"), "router")
+	_, err := c.Set(data)
+	asrt.Error(err)
+	_, err = c.Set(data)
+	asrt.NoError(err)
+	asrt.Equal(2, srv.Requests(EndpointSet))
+
+	// Rejected changes are answered with a 200 and left unapplied.
+	srv.RejectNext(EndpointSet, 1)
+	srv.RejectNext(EndpointBatch, 1)
+	path := xedgeos.ParsePath("firewall group address-group CROWDSEC address")
+	_, err = c.Set(xedgeos.SetData(path, "198.51.100.3"))
+	var changeErr *xedgeos.ChangeError
+	if asrt.ErrorAs(err, &changeErr) {
+		asrt.Equal("SET", changeErr.Op)
+		asrt.Contains(changeErr.Error(), "injected rejection")
+	}
+	_, err = c.Batch(xedgeos.BatchData{Delete: xedgeos.DeleteData(path, "198.51.100.1")})
+	if asrt.ErrorAs(err, &changeErr) {
+		asrt.Equal("DELETE", changeErr.Op)
+	}
+	asrt.Equal([]string{"198.51.100.1", "198.51.100.2"}, groupAddresses(srv, "CROWDSEC"))
+	_, err = c.Set(xedgeos.SetData(path, "198.51.100.3"))
+	asrt.NoError(err)
+
+	srv.FailNext(EndpointLogin, 10)
+	srv.Recover(EndpointLogin)
+	asrt.NoError(c.Login())
+}
+
+func TestFeatureData(t *testing.T) {
+	asrt := assert.New(t)
+	srv := NewServer(nil)
+	defer srv.Close()
+	c := newClient(t, srv)
+	asrt.NoError(c.Login())
+
+	srv.SetFeature(xedgeos.PortForwarding, xedgeos.PortForwards{WAN: "eth0"})
+	pf, err := c.PortForwards()
+	asrt.NoError(err)
+	asrt.Equal("eth0", pf.Feature.Data.WAN)
+
+	_, err = xedgeos.ApplyFeature(c, xedgeos.PortForwarding, xedgeos.PortForwards{WAN: "eth1"})
+	asrt.NoError(err)
+	pf, err = c.PortForwards()
+	asrt.NoError(err)
+	asrt.Equal("eth1", pf.Feature.Data.WAN)
+
+	srv.SetData("sys_info", xedgeos.SystemInfo{Model: "ER-X"})
+	info, err := c.SystemInfo()
+	asrt.NoError(err)
+	asrt.Equal("ER-X", info.Model)
+	_, err = c.DefaultRoute()
+	asrt.Error(err)
+}